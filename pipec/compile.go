@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -8,6 +9,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/backend/export"
 	"github.com/cncd/pipeline/pipeline/frontend"
 	"github.com/cncd/pipeline/pipeline/frontend/yaml"
 	"github.com/cncd/pipeline/pipeline/frontend/yaml/compiler"
@@ -19,303 +22,322 @@ var compileCommand = cli.Command{
 	Name:   "compile",
 	Usage:  "compile the yaml file",
 	Action: compileAction,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.StringFlag{
 			Name:  "in",
 			Value: "pipeline.yml",
 		},
 		cli.StringFlag{
 			Name:  "out",
-			Value: "pipeline.json",
-		},
-		cli.StringSliceFlag{
-			Name: "volumes",
-		},
-		cli.StringSliceFlag{
-			Name: "privileged",
-			Value: &cli.StringSlice{
-				"plugins/docker",
-				"plugins/gcr",
-				"plugins/ecr",
-			},
-		},
-		cli.StringFlag{
-			Name:  "prefix",
-			Value: "pipeline",
-		},
-		cli.BoolFlag{
-			Name: "local",
-		},
-		//
-		// volume caching
-		//
-		cli.BoolFlag{
-			Name:   "volume-cache",
-			EnvVar: "CI_VOLUME_CACHE",
-		},
-		cli.StringFlag{
-			Name:   "volume-cache-base",
-			Value:  "/var/lib/drone",
-			EnvVar: "CI_VOLUME_CACHE_BASE",
-		},
-		//
-		// s3 caching
-		//
-		cli.BoolFlag{
-			Name:   "aws-cache",
-			EnvVar: "CI_AWS_CACHE",
-		},
-		cli.StringFlag{
-			Name:   "aws-region",
-			EnvVar: "AWS_REGION",
-		},
-		cli.StringFlag{
-			Name:   "aws-bucket",
-			EnvVar: "AWS_BUCKET",
-		},
-		cli.StringFlag{
-			Name:   "aws-access-key-id",
-			EnvVar: "AWS_ACCESS_KEY_ID",
-		},
-		cli.StringFlag{
-			Name:   "aws-secret-access-key",
-			EnvVar: "AWS_SECRET_ACCESS_KEY",
-		},
-		//
-		// registry credentials
-		//
-		cli.StringFlag{
-			Name:   "registry-hostname",
-			EnvVar: "CI_REGISTRY_HOSTNAME",
-		},
-		cli.StringFlag{
-			Name:   "registry-username",
-			EnvVar: "CI_REGISTRY_USERNAME",
-		},
-		cli.StringFlag{
-			Name:   "registry-password",
-			EnvVar: "CI_REGISTRY_PASSWORD",
-		},
-		//
-		// workspace default
-		//
-		cli.StringFlag{
-			Name:  "workspace-base",
-			Value: "/pipeline",
-		},
-		cli.StringFlag{
-			Name:  "workspace-path",
-			Value: "src",
-		},
-		//
-		// netrc parameters
-		//
-		cli.StringFlag{
-			Name:   "netrc-username",
-			EnvVar: "CI_NETRC_USERNAME",
-		},
-		cli.StringFlag{
-			Name:   "netrc-password",
-			EnvVar: "CI_NETRC_PASSWORD",
-		},
-		cli.StringFlag{
-			Name:   "netrc-machine",
-			EnvVar: "CI_NETRC_MACHINE",
-		},
-		//
-		// resource limit parameters
-		//
-		cli.Int64Flag{
-			Name:   "limit-mem-swap",
-			EnvVar: "CI_LIMIT_MEM_SWAP",
-		},
-		cli.Int64Flag{
-			Name:   "limit-mem",
-			EnvVar: "CI_LIMIT_MEM",
-		},
-		cli.Int64Flag{
-			Name:   "limit-shm-size",
-			EnvVar: "CI_LIMIT_SHM_SIZE",
-		},
-		cli.Int64Flag{
-			Name:   "limit-cpu-quota",
-			EnvVar: "CI_LIMIT_CPU_QUOTA",
-		},
-		cli.Int64Flag{
-			Name:   "limit-cpu-shares",
-			EnvVar: "CI_LIMIT_CPU_SHARES",
-		},
-		cli.StringFlag{
-			Name:   "limit-cpu-set",
-			EnvVar: "CI_LIMIT_CPU_SET",
-		},
-		//
-		// metadata parameters
-		//
-		cli.StringFlag{
-			Name:   "system-arch",
-			Value:  "linux/amd64",
-			EnvVar: "CI_SYSTEM_ARCH",
-		},
-		cli.StringFlag{
-			Name:   "system-name",
-			Value:  "pipec",
-			EnvVar: "CI_SYSTEM_NAME",
-		},
-		cli.StringFlag{
-			Name:   "system-link",
-			Value:  "https://github.com/cncd/pipec",
-			EnvVar: "CI_SYSTEM_LINK",
-		},
-		cli.StringFlag{
-			Name:   "repo-name",
-			EnvVar: "CI_REPO_NAME",
-		},
-		cli.StringFlag{
-			Name:   "repo-link",
-			EnvVar: "CI_REPO_LINK",
-		},
-		cli.StringFlag{
-			Name:   "repo-remote-url",
-			EnvVar: "CI_REPO_REMOTE",
-		},
-		cli.StringFlag{
-			Name:   "repo-private",
-			EnvVar: "CI_REPO_PRIVATE",
-		},
-		cli.IntFlag{
-			Name:   "build-number",
-			EnvVar: "CI_BUILD_NUMBER",
-		},
-		cli.Int64Flag{
-			Name:   "build-created",
-			EnvVar: "CI_BUILD_CREATED",
-		},
-		cli.Int64Flag{
-			Name:   "build-started",
-			EnvVar: "CI_BUILD_STARTED",
-		},
-		cli.Int64Flag{
-			Name:   "build-finished",
-			EnvVar: "CI_BUILD_FINISHED",
+			Usage: "output file, defaults to the format file name",
 		},
 		cli.StringFlag{
-			Name:   "build-status",
-			EnvVar: "CI_BUILD_STATUS",
+			Name:  "format",
+			Usage: "output format (json, compose, k8s, sh)",
+			Value: "json",
 		},
-		cli.StringFlag{
-			Name:   "build-event",
-			EnvVar: "CI_BUILD_EVENT",
-		},
-		cli.StringFlag{
-			Name:   "build-link",
-			EnvVar: "CI_BUILD_LINK",
-		},
-		cli.StringFlag{
-			Name:   "build-target",
-			EnvVar: "CI_BUILD_TARGET",
-		},
-		cli.StringFlag{
-			Name:   "commit-sha",
-			EnvVar: "CI_COMMIT_SHA",
-		},
-		cli.StringFlag{
-			Name:   "commit-ref",
-			EnvVar: "CI_COMMIT_REF",
-		},
-		cli.StringFlag{
-			Name:   "commit-refspec",
-			EnvVar: "CI_COMMIT_REFSPEC",
-		},
-		cli.StringFlag{
-			Name:   "commit-branch",
-			EnvVar: "CI_COMMIT_BRANCH",
-		},
-		cli.StringFlag{
-			Name:   "commit-message",
-			EnvVar: "CI_COMMIT_MESSAGE",
-		},
-		cli.StringFlag{
-			Name:   "commit-author-name",
-			EnvVar: "CI_COMMIT_AUTHOR_NAME",
-		},
-		cli.StringFlag{
-			Name:   "commit-author-avatar",
-			EnvVar: "CI_COMMIT_AUTHOR_AVATAR",
-		},
-		cli.StringFlag{
-			Name:   "commit-author-email",
-			EnvVar: "CI_COMMIT_AUTHOR_EMAIL",
-		},
-		cli.IntFlag{
-			Name:   "prev-build-number",
-			EnvVar: "CI_PREV_BUILD_NUMBER",
-		},
-		cli.Int64Flag{
-			Name:   "prev-build-created",
-			EnvVar: "CI_PREV_BUILD_CREATED",
-		},
-		cli.Int64Flag{
-			Name:   "prev-build-started",
-			EnvVar: "CI_PREV_BUILD_STARTED",
-		},
-		cli.Int64Flag{
-			Name:   "prev-build-finished",
-			EnvVar: "CI_PREV_BUILD_FINISHED",
-		},
-		cli.StringFlag{
-			Name:   "prev-build-status",
-			EnvVar: "CI_PREV_BUILD_STATUS",
-		},
-		cli.StringFlag{
-			Name:   "prev-build-event",
-			EnvVar: "CI_PREV_BUILD_EVENT",
-		},
-		cli.StringFlag{
-			Name:   "prev-build-link",
-			EnvVar: "CI_PREV_BUILD_LINK",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-sha",
-			EnvVar: "CI_PREV_COMMIT_SHA",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-ref",
-			EnvVar: "CI_PREV_COMMIT_REF",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-refspec",
-			EnvVar: "CI_PREV_COMMIT_REFSPEC",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-branch",
-			EnvVar: "CI_PREV_COMMIT_BRANCH",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-message",
-			EnvVar: "CI_PREV_COMMIT_MESSAGE",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-author-name",
-			EnvVar: "CI_PREV_COMMIT_AUTHOR_NAME",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-author-avatar",
-			EnvVar: "CI_PREV_COMMIT_AUTHOR_AVATAR",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-author-email",
-			EnvVar: "CI_PREV_COMMIT_AUTHOR_EMAIL",
-		},
-		cli.IntFlag{
-			Name:   "job-number",
-			EnvVar: "CI_JOB_NUMBER",
+	}, compilerFlags...),
+}
+
+// exportFormats defines the default output file of each compile output
+// format.
+var exportFormats = map[string]string{
+	"json":    "pipeline.json",
+	"compose": "docker-compose.yml",
+	"k8s":     "pipeline.k8s.yml",
+	"sh":      "pipeline.sh",
+}
+
+// compilerFlags defines the flags used to configure the compiler and
+// the pipeline metadata.
+var compilerFlags = []cli.Flag{
+	cli.StringSliceFlag{
+		Name: "volumes",
+	},
+	cli.StringSliceFlag{
+		Name: "privileged",
+		Value: &cli.StringSlice{
+			"plugins/docker",
+			"plugins/gcr",
+			"plugins/ecr",
 		},
-		// cli.StringFlag{
-		// 	Name:   "job-matrix",
-		// 	EnvVar: "CI_JOB_MATRIX",
-		// },
 	},
+	cli.StringFlag{
+		Name:  "prefix",
+		Value: "pipeline",
+	},
+	cli.BoolFlag{
+		Name: "local",
+	},
+	//
+	// volume caching
+	//
+	cli.BoolFlag{
+		Name:   "volume-cache",
+		EnvVar: "CI_VOLUME_CACHE",
+	},
+	cli.StringFlag{
+		Name:   "volume-cache-base",
+		Value:  "/var/lib/drone",
+		EnvVar: "CI_VOLUME_CACHE_BASE",
+	},
+	//
+	// s3 caching
+	//
+	cli.BoolFlag{
+		Name:   "aws-cache",
+		EnvVar: "CI_AWS_CACHE",
+	},
+	cli.StringFlag{
+		Name:   "aws-region",
+		EnvVar: "AWS_REGION",
+	},
+	cli.StringFlag{
+		Name:   "aws-bucket",
+		EnvVar: "AWS_BUCKET",
+	},
+	cli.StringFlag{
+		Name:   "aws-access-key-id",
+		EnvVar: "AWS_ACCESS_KEY_ID",
+	},
+	cli.StringFlag{
+		Name:   "aws-secret-access-key",
+		EnvVar: "AWS_SECRET_ACCESS_KEY",
+	},
+	//
+	// registry credentials
+	//
+	cli.StringFlag{
+		Name:   "registry-hostname",
+		EnvVar: "CI_REGISTRY_HOSTNAME",
+	},
+	cli.StringFlag{
+		Name:   "registry-username",
+		EnvVar: "CI_REGISTRY_USERNAME",
+	},
+	cli.StringFlag{
+		Name:   "registry-password",
+		EnvVar: "CI_REGISTRY_PASSWORD",
+	},
+	//
+	// workspace default
+	//
+	cli.StringFlag{
+		Name:  "workspace-base",
+		Value: "/pipeline",
+	},
+	cli.StringFlag{
+		Name:  "workspace-path",
+		Value: "src",
+	},
+	//
+	// netrc parameters
+	//
+	cli.StringFlag{
+		Name:   "netrc-username",
+		EnvVar: "CI_NETRC_USERNAME",
+	},
+	cli.StringFlag{
+		Name:   "netrc-password",
+		EnvVar: "CI_NETRC_PASSWORD",
+	},
+	cli.StringFlag{
+		Name:   "netrc-machine",
+		EnvVar: "CI_NETRC_MACHINE",
+	},
+	//
+	// resource limit parameters
+	//
+	cli.Int64Flag{
+		Name:   "limit-mem-swap",
+		EnvVar: "CI_LIMIT_MEM_SWAP",
+	},
+	cli.Int64Flag{
+		Name:   "limit-mem",
+		EnvVar: "CI_LIMIT_MEM",
+	},
+	cli.Int64Flag{
+		Name:   "limit-shm-size",
+		EnvVar: "CI_LIMIT_SHM_SIZE",
+	},
+	cli.Int64Flag{
+		Name:   "limit-cpu-quota",
+		EnvVar: "CI_LIMIT_CPU_QUOTA",
+	},
+	cli.Int64Flag{
+		Name:   "limit-cpu-shares",
+		EnvVar: "CI_LIMIT_CPU_SHARES",
+	},
+	cli.StringFlag{
+		Name:   "limit-cpu-set",
+		EnvVar: "CI_LIMIT_CPU_SET",
+	},
+	//
+	// metadata parameters
+	//
+	cli.StringFlag{
+		Name:   "system-arch",
+		Value:  "linux/amd64",
+		EnvVar: "CI_SYSTEM_ARCH",
+	},
+	cli.StringFlag{
+		Name:   "system-name",
+		Value:  "pipec",
+		EnvVar: "CI_SYSTEM_NAME",
+	},
+	cli.StringFlag{
+		Name:   "system-link",
+		Value:  "https://github.com/cncd/pipec",
+		EnvVar: "CI_SYSTEM_LINK",
+	},
+	cli.StringFlag{
+		Name:   "repo-name",
+		EnvVar: "CI_REPO_NAME",
+	},
+	cli.StringFlag{
+		Name:   "repo-link",
+		EnvVar: "CI_REPO_LINK",
+	},
+	cli.StringFlag{
+		Name:   "repo-remote-url",
+		EnvVar: "CI_REPO_REMOTE",
+	},
+	cli.StringFlag{
+		Name:   "repo-private",
+		EnvVar: "CI_REPO_PRIVATE",
+	},
+	cli.IntFlag{
+		Name:   "build-number",
+		EnvVar: "CI_BUILD_NUMBER",
+	},
+	cli.Int64Flag{
+		Name:   "build-created",
+		EnvVar: "CI_BUILD_CREATED",
+	},
+	cli.Int64Flag{
+		Name:   "build-started",
+		EnvVar: "CI_BUILD_STARTED",
+	},
+	cli.Int64Flag{
+		Name:   "build-finished",
+		EnvVar: "CI_BUILD_FINISHED",
+	},
+	cli.StringFlag{
+		Name:   "build-status",
+		EnvVar: "CI_BUILD_STATUS",
+	},
+	cli.StringFlag{
+		Name:   "build-event",
+		EnvVar: "CI_BUILD_EVENT",
+	},
+	cli.StringFlag{
+		Name:   "build-link",
+		EnvVar: "CI_BUILD_LINK",
+	},
+	cli.StringFlag{
+		Name:   "build-target",
+		EnvVar: "CI_BUILD_TARGET",
+	},
+	cli.StringFlag{
+		Name:   "commit-sha",
+		EnvVar: "CI_COMMIT_SHA",
+	},
+	cli.StringFlag{
+		Name:   "commit-ref",
+		EnvVar: "CI_COMMIT_REF",
+	},
+	cli.StringFlag{
+		Name:   "commit-refspec",
+		EnvVar: "CI_COMMIT_REFSPEC",
+	},
+	cli.StringFlag{
+		Name:   "commit-branch",
+		EnvVar: "CI_COMMIT_BRANCH",
+	},
+	cli.StringFlag{
+		Name:   "commit-message",
+		EnvVar: "CI_COMMIT_MESSAGE",
+	},
+	cli.StringFlag{
+		Name:   "commit-author-name",
+		EnvVar: "CI_COMMIT_AUTHOR_NAME",
+	},
+	cli.StringFlag{
+		Name:   "commit-author-avatar",
+		EnvVar: "CI_COMMIT_AUTHOR_AVATAR",
+	},
+	cli.StringFlag{
+		Name:   "commit-author-email",
+		EnvVar: "CI_COMMIT_AUTHOR_EMAIL",
+	},
+	cli.IntFlag{
+		Name:   "prev-build-number",
+		EnvVar: "CI_PREV_BUILD_NUMBER",
+	},
+	cli.Int64Flag{
+		Name:   "prev-build-created",
+		EnvVar: "CI_PREV_BUILD_CREATED",
+	},
+	cli.Int64Flag{
+		Name:   "prev-build-started",
+		EnvVar: "CI_PREV_BUILD_STARTED",
+	},
+	cli.Int64Flag{
+		Name:   "prev-build-finished",
+		EnvVar: "CI_PREV_BUILD_FINISHED",
+	},
+	cli.StringFlag{
+		Name:   "prev-build-status",
+		EnvVar: "CI_PREV_BUILD_STATUS",
+	},
+	cli.StringFlag{
+		Name:   "prev-build-event",
+		EnvVar: "CI_PREV_BUILD_EVENT",
+	},
+	cli.StringFlag{
+		Name:   "prev-build-link",
+		EnvVar: "CI_PREV_BUILD_LINK",
+	},
+	cli.StringFlag{
+		Name:   "prev-commit-sha",
+		EnvVar: "CI_PREV_COMMIT_SHA",
+	},
+	cli.StringFlag{
+		Name:   "prev-commit-ref",
+		EnvVar: "CI_PREV_COMMIT_REF",
+	},
+	cli.StringFlag{
+		Name:   "prev-commit-refspec",
+		EnvVar: "CI_PREV_COMMIT_REFSPEC",
+	},
+	cli.StringFlag{
+		Name:   "prev-commit-branch",
+		EnvVar: "CI_PREV_COMMIT_BRANCH",
+	},
+	cli.StringFlag{
+		Name:   "prev-commit-message",
+		EnvVar: "CI_PREV_COMMIT_MESSAGE",
+	},
+	cli.StringFlag{
+		Name:   "prev-commit-author-name",
+		EnvVar: "CI_PREV_COMMIT_AUTHOR_NAME",
+	},
+	cli.StringFlag{
+		Name:   "prev-commit-author-avatar",
+		EnvVar: "CI_PREV_COMMIT_AUTHOR_AVATAR",
+	},
+	cli.StringFlag{
+		Name:   "prev-commit-author-email",
+		EnvVar: "CI_PREV_COMMIT_AUTHOR_EMAIL",
+	},
+	cli.IntFlag{
+		Name:   "job-number",
+		EnvVar: "CI_JOB_NUMBER",
+	},
+	// cli.StringFlag{
+	// 	Name:   "job-matrix",
+	// 	EnvVar: "CI_JOB_MATRIX",
+	// },
 }
 
 func compileAction(c *cli.Context) (err error) {
@@ -324,14 +346,77 @@ func compileAction(c *cli.Context) (err error) {
 		file = c.String("in")
 	}
 
+	format := c.String("format")
+	output := c.String("out")
+	if _, ok := exportFormats[format]; !ok {
+		return fmt.Errorf("Error: unknown format %q, expected json, compose, k8s or sh", format)
+	}
+	if output == "" {
+		output = exportFormats[format]
+	}
+
 	conf, err := yaml.ParseFile(file)
 	if err != nil {
 		return err
 	}
 
+	// compiles the yaml file
+	compiled := compile(c, file, conf, metadataFromContext(c), c.String("prefix"), c.Bool("local"))
+
+	// export the compiled spec to the output format
+	var buf bytes.Buffer
+	switch format {
+	case "compose":
+		err = export.Compose(&buf, compiled)
+	case "k8s":
+		err = export.Kubernetes(&buf, compiled, c.String("prefix"))
+	case "sh":
+		err = export.Shell(&buf, compiled)
+	default:
+		var out []byte
+		out, err = json.MarshalIndent(compiled, "", "  ")
+		buf.Write(out)
+	}
+	if err != nil {
+		return err
+	}
+
+	// create output file with option to dump to stdout
+	var writer = os.Stdout
+	if output != "-" {
+		mode := os.FileMode(0644)
+		if format == "sh" {
+			mode = 0755
+		}
+		writer, err = os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+		if err != nil {
+			return err
+		}
+	}
+	defer writer.Close()
+
+	_, err = writer.Write(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if writer != os.Stdout {
+		fmt.Fprintf(os.Stdout, "Successfully compiled %s to %s\n", file, output)
+	}
+	return nil
+}
+
+// compile compiles the parsed yaml file using the compiler flags.
+func compile(c *cli.Context, file string, conf *yaml.Config, metadata frontend.Metadata, prefix string, local bool) *backend.Config {
+	return newCompiler(c, file, conf, metadata, prefix, local).Compile(conf)
+}
+
+// newCompiler returns a compiler for the parsed yaml file configured
+// with the compiler flags.
+func newCompiler(c *cli.Context, file string, conf *yaml.Config, metadata frontend.Metadata, prefix string, local bool) *compiler.Compiler {
 	// configure volumes for local execution
 	volumes := c.StringSlice("volumes")
-	if c.Bool("local") {
+	if local {
 		var (
 			workspaceBase = conf.Workspace.Base
 			workspacePath = conf.Workspace.Path
@@ -356,8 +441,7 @@ func compileAction(c *cli.Context) (err error) {
 		})
 	}
 
-	// compiles the yaml file
-	compiled := compiler.New(
+	return compiler.New(
 		compiler.WithResourceLimit(
 			c.Int64("limit-mem-swap"),
 			c.Int64("limit-mem"),
@@ -382,21 +466,15 @@ func compileAction(c *cli.Context) (err error) {
 			c.String("workspace-base"),
 			c.String("workspace-path"),
 		),
-		compiler.WithPrefix(
-			c.String("prefix"),
-		),
+		compiler.WithPrefix(prefix),
 		compiler.WithProxy(),
-		compiler.WithLocal(
-			c.Bool("local"),
-		),
+		compiler.WithLocal(local),
 		compiler.WithNetrc(
 			c.String("netrc-username"),
 			c.String("netrc-password"),
 			c.String("netrc-machine"),
 		),
-		compiler.WithMetadata(
-			metadataFromContext(c),
-		),
+		compiler.WithMetadata(metadata),
 		compiler.WithOption(
 			compiler.WithVolumeCacher(
 				c.String("volume-cache-base"),
@@ -412,34 +490,7 @@ func compileAction(c *cli.Context) (err error) {
 			),
 			c.Bool("aws-cache"),
 		),
-	).Compile(conf)
-
-	// marshal the compiled spec to formatted yaml
-	out, err := json.MarshalIndent(compiled, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	// create output file with option to dump to stdout
-	var writer = os.Stdout
-	output := c.String("out")
-	if output != "-" {
-		writer, err = os.Create(output)
-		if err != nil {
-			return err
-		}
-	}
-	defer writer.Close()
-
-	_, err = writer.Write(out)
-	if err != nil {
-		return err
-	}
-
-	if writer != os.Stdout {
-		fmt.Fprintf(os.Stdout, "Successfully compiled %s to %s\n", file, output)
-	}
-	return nil
+	)
 }
 
 // return the metadata from the cli context.
@@ -2,10 +2,8 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/cncd/pipeline/pipeline"
@@ -13,7 +11,6 @@ import (
 	"github.com/cncd/pipeline/pipeline/backend/docker"
 	"github.com/cncd/pipeline/pipeline/backend/kubernetes"
 	"github.com/cncd/pipeline/pipeline/interrupt"
-	"github.com/cncd/pipeline/pipeline/multipart"
 	"github.com/urfave/cli"
 )
 
@@ -21,33 +18,64 @@ var executeCommand = cli.Command{
 	Name:   "exec",
 	Usage:  "execute the compiled file",
 	Action: executeAction,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.StringFlag{
 			Name:  "in",
 			Value: "pipeline.json",
 		},
-		cli.DurationFlag{
-			Name:   "timeout",
-			EnvVar: "CI_TIMEOUT",
-			Value:  time.Hour,
-		},
-		cli.BoolFlag{
-			Name:   "kubernetes",
-			EnvVar: "CI_KUBERNETES",
-		},
-		cli.StringFlag{
-			Name:   "kubernetes-namepsace",
-			EnvVar: "CI_KUBERNETES_NAMESPACE",
-			Value:  "default",
-		},
-		cli.StringFlag{
-			Name:   "kubernetes-endpoint",
-			EnvVar: "CI_KUBERNETES_ENDPOINT",
-		},
-		cli.StringFlag{
-			Name:   "kubernetes-token",
-			EnvVar: "CI_KUBERNETES_TOKEN",
-		},
+	}, executeFlags...),
+}
+
+// executeFlags defines the flags used to configure the execution and
+// the output.
+var executeFlags = []cli.Flag{
+	cli.DurationFlag{
+		Name:   "timeout",
+		EnvVar: "CI_TIMEOUT",
+		Value:  time.Hour,
+	},
+	cli.StringSliceFlag{
+		Name:  "only",
+		Usage: "execute only the named steps, may be repeated or comma separated",
+	},
+	cli.StringSliceFlag{
+		Name:  "skip",
+		Usage: "skip the named steps, may be repeated or comma separated",
+	},
+	cli.StringFlag{
+		Name:  "from",
+		Usage: "execute the named step and every step after it",
+	},
+	cli.StringFlag{
+		Name:  "output",
+		Usage: "output format, text or json",
+		Value: "text",
+	},
+	cli.BoolFlag{
+		Name:  "verbose",
+		Usage: "stream the output of every step, including passing steps",
+	},
+	cli.BoolFlag{
+		Name:   "no-color",
+		EnvVar: "NO_COLOR",
+		Usage:  "disable colored output",
+	},
+	cli.BoolFlag{
+		Name:   "kubernetes",
+		EnvVar: "CI_KUBERNETES",
+	},
+	cli.StringFlag{
+		Name:   "kubernetes-namepsace",
+		EnvVar: "CI_KUBERNETES_NAMESPACE",
+		Value:  "default",
+	},
+	cli.StringFlag{
+		Name:   "kubernetes-endpoint",
+		EnvVar: "CI_KUBERNETES_ENDPOINT",
+	},
+	cli.StringFlag{
+		Name:   "kubernetes-token",
+		EnvVar: "CI_KUBERNETES_TOKEN",
 	},
 }
 
@@ -73,6 +101,29 @@ func executeAction(c *cli.Context) (err error) {
 		return err
 	}
 
+	return execute(c, config, "")
+}
+
+// execute executes the selected steps of the compiled configuration,
+// and prints the step output and summary. The label identifies the
+// matrix axis in the output, if any.
+func execute(c *cli.Context, config *backend.Config, label string) error {
+	selected, excluded, err := selectSteps(config,
+		c.StringSlice("only"),
+		c.StringSlice("skip"),
+		c.String("from"),
+	)
+	if err != nil {
+		return err
+	}
+
+	opts := outputFromContext(c)
+	opts.label = label
+	out, err := newPrinter(os.Stdout, config, opts)
+	if err != nil {
+		return err
+	}
+
 	var engine backend.Engine
 	if c.Bool("kubernetes") {
 		engine = kubernetes.New(
@@ -91,33 +142,14 @@ func executeAction(c *cli.Context) (err error) {
 	defer cancel()
 	ctx = interrupt.WithContext(ctx)
 
-	return pipeline.New(config,
+	traceExcluded(out, excluded)
+	r := pipeline.New(selected,
 		pipeline.WithContext(ctx),
-		pipeline.WithLogger(defaultLogger),
-		pipeline.WithTracer(defaultTracer),
+		pipeline.WithLogger(out),
+		pipeline.WithTracer(out),
 		pipeline.WithEngine(engine),
-	).Run()
+	)
+	err = r.Run()
+	out.Summary(mergeStatus(config, r.Status()), err)
+	return err
 }
-
-var defaultLogger = pipeline.LogFunc(func(proc *backend.Step, rc multipart.Reader) error {
-	part, err := rc.NextPart()
-	if err != nil {
-		return err
-	}
-	io.Copy(os.Stderr, part)
-	return nil
-})
-
-var defaultTracer = pipeline.TraceFunc(func(state *pipeline.State) error {
-	if state.Process.Exited {
-		fmt.Printf("proc %q exited with status %d\n", state.Pipeline.Step.Name, state.Process.ExitCode)
-	} else {
-		fmt.Printf("proc %q started\n", state.Pipeline.Step.Name)
-		state.Pipeline.Step.Environment["CI_BUILD_STATUS"] = "success"
-		state.Pipeline.Step.Environment["CI_BUILD_FINISHED"] = strconv.FormatInt(time.Now().Unix(), 10)
-		if state.Pipeline.Error != nil {
-			state.Pipeline.Step.Environment["CI_BUILD_STATUS"] = "failure"
-		}
-	}
-	return nil
-})
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cncd/pipeline/pipeline/frontend/yaml"
+
+	"github.com/urfave/cli"
+)
+
+var explainCommand = cli.Command{
+	Name:      "explain",
+	Usage:     "explain why each container is included or skipped",
+	ArgsUsage: "[pipeline.yml]",
+	Action:    explainAction,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "in",
+			Value: "pipeline.yml",
+		},
+		cli.StringFlag{
+			Name:  "output",
+			Usage: "output format (text, json)",
+			Value: "text",
+		},
+	}, compilerFlags...),
+}
+
+func explainAction(c *cli.Context) error {
+	file := c.Args().First()
+	if file == "" {
+		file = c.String("in")
+	}
+
+	conf, err := yaml.ParseFile(file)
+	if err != nil {
+		return err
+	}
+
+	decisions := newCompiler(c, file, conf, metadataFromContext(c), c.String("prefix"), c.Bool("local")).Explain(conf)
+
+	switch c.String("output") {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(decisions)
+	case "text":
+	default:
+		return fmt.Errorf("Error: unknown output format %q, expected text or json", c.String("output"))
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTAINER\tSECTION\tDECISION\tREASONS")
+	for _, d := range decisions {
+		decision := "skip"
+		if d.Include {
+			decision = "include"
+		}
+		reasons := strings.Join(d.Reasons(), "; ")
+		if reasons == "" {
+			reasons = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Name, d.Section, decision, reasons)
+	}
+	return tw.Flush()
+}
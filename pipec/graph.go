@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/frontend/yaml"
+	"github.com/cncd/pipeline/pipeline/frontend/yaml/compiler"
+
+	"github.com/urfave/cli"
+)
+
+var graphCommand = cli.Command{
+	Name:      "graph",
+	Usage:     "render the pipeline stages and steps as a graph",
+	ArgsUsage: "[pipeline.yml|pipeline.json]",
+	Action:    graphAction,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "in",
+			Value: "pipeline.yml",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "graph format (dot, mermaid)",
+			Value: "dot",
+		},
+	}, compilerFlags...),
+}
+
+// graphStage defines a pipeline stage in the graph.
+type graphStage struct {
+	name  string
+	steps []*graphStep
+}
+
+// graphStep defines a pipeline step in the graph.
+type graphStep struct {
+	id      string
+	name    string
+	service bool
+	edge    string // on_success, on_failure or always
+	reason  string // reason the step is excluded, if any
+}
+
+// graph defines the pipeline graph.
+type graph struct {
+	stages   []*graphStage
+	excluded []*graphStep
+}
+
+func graphAction(c *cli.Context) error {
+	file := c.Args().First()
+	if file == "" {
+		file = c.String("in")
+	}
+
+	var g *graph
+	if strings.HasSuffix(file, ".json") {
+		config := new(backend.Config)
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := json.NewDecoder(f).Decode(config); err != nil {
+			return err
+		}
+		g = newGraph(config, nil)
+	} else {
+		conf, err := yaml.ParseFile(file)
+		if err != nil {
+			return err
+		}
+		compiler := newCompiler(c, file, conf, metadataFromContext(c), c.String("prefix"), c.Bool("local"))
+		g = newGraph(compiler.Compile(conf), excludedSteps(compiler.Explain(conf)))
+	}
+
+	switch c.String("format") {
+	case "dot":
+		fmt.Print(g.dot())
+	case "mermaid":
+		fmt.Print(g.mermaid())
+	default:
+		return fmt.Errorf("Error: unknown format %q, expected dot or mermaid", c.String("format"))
+	}
+	return nil
+}
+
+// newGraph returns the graph of the compiled configuration and the
+// steps excluded by the compiler.
+func newGraph(config *backend.Config, excluded []*graphStep) *graph {
+	g := new(graph)
+	var n int
+	for _, stage := range config.Stages {
+		if len(stage.Steps) == 0 {
+			continue
+		}
+		s := &graphStage{name: stage.Alias}
+		if s.name == "" {
+			s.name = stage.Name
+		}
+		for _, proc := range stage.Steps {
+			s.steps = append(s.steps, &graphStep{
+				id:      fmt.Sprintf("n%d", n),
+				name:    stepName(proc),
+				service: proc.Detached,
+				edge:    edgeOf(proc),
+			})
+			n++
+		}
+		g.stages = append(g.stages, s)
+	}
+	for _, step := range excluded {
+		step.id = fmt.Sprintf("n%d", n)
+		g.excluded = append(g.excluded, step)
+		n++
+	}
+	return g
+}
+
+// edgeOf returns the kind of edge leading to the step.
+func edgeOf(proc *backend.Step) string {
+	switch {
+	case proc.OnSuccess && proc.OnFailure:
+		return "always"
+	case proc.OnFailure:
+		return "on_failure"
+	default:
+		return "on_success"
+	}
+}
+
+// excludedSteps returns the containers excluded by the compiler, and
+// the reason each container is excluded.
+func excludedSteps(decisions []compiler.Decision) []*graphStep {
+	var excluded []*graphStep
+	for _, d := range decisions {
+		if d.Include {
+			continue
+		}
+		excluded = append(excluded, &graphStep{
+			name:    d.Name,
+			service: d.Section == "services",
+			reason:  strings.Join(d.Reasons(), "\n"),
+		})
+	}
+	return excluded
+}
+
+// dot returns the graph in the graphviz dot format.
+func (g *graph) dot() string {
+	var b bytes.Buffer
+	b.WriteString("digraph pipeline {\n")
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [shape=box];\n")
+	for i, stage := range g.stages {
+		fmt.Fprintf(&b, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&b, "    label=%s;\n", dotQuote(stageLabel(stage)))
+		for _, step := range stage.steps {
+			shape := "box"
+			if step.service {
+				shape = "ellipse"
+			}
+			fmt.Fprintf(&b, "    %s [label=%s, shape=%s];\n", step.id, dotQuote(step.name), shape)
+		}
+		b.WriteString("  }\n")
+	}
+	g.edges(func(from, to *graphStep) {
+		var attrs string
+		switch to.edge {
+		case "on_failure":
+			attrs = ", color=red, style=dashed"
+		case "always":
+			attrs = ", style=bold"
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%s%s];\n", from.id, to.id, dotQuote(to.edge), attrs)
+	})
+	if len(g.excluded) != 0 {
+		b.WriteString("  subgraph cluster_excluded {\n")
+		b.WriteString("    label=\"excluded\";\n")
+		b.WriteString("    style=dashed;\n")
+		for _, step := range g.excluded {
+			fmt.Fprintf(&b, "    %s [label=%s, style=dashed, fontcolor=gray];\n", step.id, dotQuote(step.name+"\n"+step.reason))
+		}
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// mermaid returns the graph in the mermaid flowchart format.
+func (g *graph) mermaid() string {
+	var b bytes.Buffer
+	b.WriteString("flowchart TD\n")
+	for i, stage := range g.stages {
+		fmt.Fprintf(&b, "  subgraph s%d [%s]\n", i, mermaidQuote(stageLabel(stage)))
+		for _, step := range stage.steps {
+			if step.service {
+				fmt.Fprintf(&b, "    %s([%s])\n", step.id, mermaidQuote(step.name))
+			} else {
+				fmt.Fprintf(&b, "    %s[%s]\n", step.id, mermaidQuote(step.name))
+			}
+		}
+		b.WriteString("  end\n")
+	}
+	g.edges(func(from, to *graphStep) {
+		arrow := "-->"
+		switch to.edge {
+		case "on_failure":
+			arrow = "-.->"
+		case "always":
+			arrow = "==>"
+		}
+		fmt.Fprintf(&b, "  %s %s|%s| %s\n", from.id, arrow, to.edge, to.id)
+	})
+	if len(g.excluded) != 0 {
+		b.WriteString("  subgraph excluded [\"excluded\"]\n")
+		for _, step := range g.excluded {
+			fmt.Fprintf(&b, "    %s[%s]\n", step.id, mermaidQuote(step.name+"<br/>"+strings.Replace(step.reason, "\n", "<br/>", -1)))
+		}
+		b.WriteString("  end\n")
+		b.WriteString("  classDef excluded stroke-dasharray: 5 5,color:#999\n")
+		for _, step := range g.excluded {
+			fmt.Fprintf(&b, "  class %s excluded\n", step.id)
+		}
+	}
+	return b.String()
+}
+
+// edges calls the function for every edge between the steps of
+// consecutive stages.
+func (g *graph) edges(fn func(from, to *graphStep)) {
+	for i := 1; i < len(g.stages); i++ {
+		for _, from := range g.stages[i-1].steps {
+			for _, to := range g.stages[i].steps {
+				fn(from, to)
+			}
+		}
+	}
+}
+
+// stageLabel returns the stage label. Stages with more than one step
+// run their steps in parallel.
+func stageLabel(stage *graphStage) string {
+	if len(stage.steps) > 1 {
+		return stage.name + " (parallel)"
+	}
+	return stage.name
+}
+
+func dotQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return `"` + s + `"`
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, "#quot;", -1) + `"`
+}
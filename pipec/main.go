@@ -16,6 +16,9 @@ func main() {
 	app.Commands = []cli.Command{
 		compileCommand,
 		executeCommand,
+		runCommand,
+		graphCommand,
+		explainCommand,
 		lintCommand,
 	}
 
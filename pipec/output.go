@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline"
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/multipart"
+
+	"github.com/urfave/cli"
+)
+
+// printer reports the pipeline progress, step output and summary.
+type printer interface {
+	pipeline.Tracer
+	pipeline.Logger
+
+	// Summary reports the final status of every step.
+	Summary(status []pipeline.StepStatus, err error)
+}
+
+// outputOptions configures the printer.
+type outputOptions struct {
+	format  string
+	color   bool
+	verbose bool
+	label   string
+}
+
+// outputFromContext returns the printer options from the cli context.
+func outputFromContext(c *cli.Context) outputOptions {
+	return outputOptions{
+		format:  c.String("output"),
+		color:   isTerminal(os.Stdout) && !c.Bool("no-color"),
+		verbose: c.Bool("verbose"),
+	}
+}
+
+// newPrinter returns the printer for the output format.
+func newPrinter(w io.Writer, config *backend.Config, opts outputOptions) (printer, error) {
+	switch opts.format {
+	case "", "text":
+		return newTextPrinter(w, config, opts), nil
+	case "json":
+		return newJSONPrinter(w, opts.label), nil
+	default:
+		return nil, fmt.Errorf("Error: unknown output format %q, expected text or json", opts.format)
+	}
+}
+
+// isTerminal returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// stepName returns the step name as written in the yaml file.
+func stepName(proc *backend.Step) string {
+	if proc.Alias != "" {
+		return proc.Alias
+	}
+	return proc.Name
+}
+
+// setBuildStatus exposes the pipeline status to the step environment,
+// before the step is started.
+func setBuildStatus(state *pipeline.State) {
+	env := state.Pipeline.Step.Environment
+	if env == nil {
+		return
+	}
+	env["CI_BUILD_STATUS"] = "success"
+	env["CI_BUILD_FINISHED"] = strconv.FormatInt(time.Now().Unix(), 10)
+	if state.Pipeline.Error != nil {
+		env["CI_BUILD_STATUS"] = "failure"
+	}
+}
+
+// describe returns the pipeline error message, using the step names
+// as written in the yaml file.
+func describe(err error, status []pipeline.StepStatus) string {
+	alias := func(name string) string {
+		for _, s := range status {
+			if s.Name == name && s.Alias != "" {
+				return s.Alias
+			}
+		}
+		return name
+	}
+	switch xerr := err.(type) {
+	case *pipeline.ExitError:
+		return fmt.Sprintf("step %s exited with code %d", alias(xerr.Name), xerr.Code)
+	case *pipeline.OomError:
+		return fmt.Sprintf("step %s received oom kill", alias(xerr.Name))
+	default:
+		return err.Error()
+	}
+}
+
+// maxLineSize is the maximum size of a log line.
+const maxLineSize = 1024 * 1024
+
+// helper function invokes fn for each line of the step output.
+func scanLines(rc multipart.Reader, fn func(string)) error {
+	part, err := rc.NextPart()
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(part)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+//
+// text printer.
+//
+
+// ansi escape codes.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+// palette defines the colors assigned to the step prefixes.
+var palette = []string{
+	"\x1b[36m",
+	"\x1b[35m",
+	"\x1b[34m",
+	"\x1b[33m",
+	"\x1b[32m",
+	"\x1b[96m",
+	"\x1b[95m",
+	"\x1b[94m",
+}
+
+// logWait is the time the printer waits for the step output to be
+// fully read after the step exits.
+const logWait = 2 * time.Second
+
+type textPrinter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	color   bool
+	verbose bool
+	label   string
+	width   int
+	colors  map[string]string
+	steps   map[string]*textStep
+}
+
+// textStep defines the output and timing of a step.
+type textStep struct {
+	lines    []string
+	started  time.Time
+	finished time.Time
+	done     chan struct{}
+}
+
+// newTextPrinter returns a printer that writes human friendly output.
+// The output of each step is prefixed with the step name and printed
+// when the step exits, and the output of passing steps is collapsed
+// unless verbose is true. The output of services, and all output in
+// verbose mode, is streamed as it is received.
+func newTextPrinter(w io.Writer, config *backend.Config, opts outputOptions) *textPrinter {
+	p := &textPrinter{
+		w:       w,
+		color:   opts.color,
+		verbose: opts.verbose,
+		label:   opts.label,
+		colors:  map[string]string{},
+		steps:   map[string]*textStep{},
+	}
+	for _, stage := range config.Stages {
+		for _, proc := range stage.Steps {
+			name := stepName(proc)
+			if _, ok := p.colors[name]; !ok {
+				p.colors[name] = palette[len(p.colors)%len(palette)]
+			}
+			if len(name) > p.width {
+				p.width = len(name)
+			}
+		}
+	}
+	return p
+}
+
+func (p *textPrinter) Trace(state *pipeline.State) error {
+	proc := state.Pipeline.Step
+	status := state.Status
+	step := p.step(proc)
+
+	switch {
+	case status.State == pipeline.StateSkipped:
+		p.printf("%s %s %s\n", p.timestamp(), p.prefix(proc), p.paint(ansiDim, "○ skipped: "+status.Reason))
+	case !state.Process.Exited:
+		setBuildStatus(state)
+		p.mu.Lock()
+		step.started = time.Now()
+		p.mu.Unlock()
+		p.printf("%s %s %s\n", p.timestamp(), p.prefix(proc), p.paint(ansiDim, "started"))
+	default:
+		select {
+		case <-step.done:
+		case <-time.After(logWait):
+		}
+		p.mu.Lock()
+		step.finished = time.Now()
+		lines := step.lines
+		step.lines = nil
+		var elapsed time.Duration
+		if !step.started.IsZero() {
+			elapsed = step.finished.Sub(step.started)
+		}
+		p.mu.Unlock()
+
+		collapse := status.State == pipeline.StateSuccess && !p.verbose
+		if collapse && len(lines) != 0 {
+			p.printf("%s %s %s\n", p.timestamp(), p.prefix(proc), p.paint(ansiDim, fmt.Sprintf("%d lines of output hidden, use --verbose to show", len(lines))))
+		} else {
+			for _, line := range lines {
+				p.printf("%s\n", line)
+			}
+		}
+		p.printf("%s %s %s\n", p.timestamp(), p.prefix(proc), p.result(status, elapsed))
+		if usage := state.Process.Usage; usage != nil {
+			p.printf("%s %s %s\n", p.timestamp(), p.prefix(proc), p.paint(ansiDim, fmt.Sprintf("used %d bytes peak memory, %.2f cpu seconds", usage.MemoryPeak, usage.CPUSeconds)))
+		}
+	}
+	return nil
+}
+
+func (p *textPrinter) Log(proc *backend.Step, rc multipart.Reader) error {
+	step := p.step(proc)
+	defer close(step.done)
+
+	stream := p.verbose || proc.Detached
+	return scanLines(rc, func(text string) {
+		line := p.timestamp() + " " + p.prefix(proc) + " " + text
+		if stream {
+			p.printf("%s\n", line)
+			return
+		}
+		p.mu.Lock()
+		step.lines = append(step.lines, line)
+		p.mu.Unlock()
+	})
+}
+
+func (p *textPrinter) Summary(status []pipeline.StepStatus, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// the summary is written at once so that it is not interleaved
+	// with the output of other matrix axes.
+	buf := new(bytes.Buffer)
+	defer func() {
+		p.w.Write(buf.Bytes())
+	}()
+
+	fmt.Fprintln(buf)
+	if p.label != "" {
+		fmt.Fprintln(buf, p.paint(ansiBold, p.label))
+	}
+	tw := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tSTATUS\tDURATION\tEXIT CODE")
+	for _, s := range status {
+		name := s.Alias
+		if name == "" {
+			name = s.Name
+		}
+		duration := "-"
+		if step, ok := p.steps[s.Name]; ok && !step.started.IsZero() && !step.finished.IsZero() {
+			duration = round(step.finished.Sub(step.started)).String()
+		} else if s.Started != 0 && s.Finished != 0 {
+			duration = (time.Duration(s.Finished-s.Started) * time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", name, s.State, duration, s.ExitCode)
+	}
+	tw.Flush()
+
+	if err != nil {
+		fmt.Fprintf(buf, "\n%s\n", p.paint(ansiRed+ansiBold, "pipeline failed: "+describe(err, status)))
+	} else {
+		fmt.Fprintf(buf, "\n%s\n", p.paint(ansiGreen+ansiBold, "pipeline succeeded"))
+	}
+}
+
+// step returns the output and timing of the step.
+func (p *textPrinter) step(proc *backend.Step) *textStep {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	step, ok := p.steps[proc.Name]
+	if !ok {
+		step = &textStep{done: make(chan struct{})}
+		p.steps[proc.Name] = step
+	}
+	return step
+}
+
+// prefix returns the colored and padded step name.
+func (p *textPrinter) prefix(proc *backend.Step) string {
+	name := stepName(proc)
+	prefix := p.paint(p.colors[name], fmt.Sprintf("%-*s |", p.width, name))
+	if p.label != "" {
+		prefix = p.paint(ansiBold, p.label) + " " + prefix
+	}
+	return prefix
+}
+
+// result returns the colored step result.
+func (p *textPrinter) result(status pipeline.StepStatus, elapsed time.Duration) string {
+	text := string(status.State)
+	if elapsed != 0 {
+		text += " in " + round(elapsed).String()
+	}
+	if status.ExitCode != 0 {
+		text += fmt.Sprintf(" (exit code %d)", status.ExitCode)
+	}
+	if status.Reason != "" {
+		text += ": " + status.Reason
+	}
+	switch status.State {
+	case pipeline.StateSuccess:
+		return p.paint(ansiGreen, "✓ "+text)
+	case pipeline.StateFailure:
+		if status.Reason == pipeline.ReasonAllowed {
+			return p.paint(ansiYellow, "! "+text)
+		}
+		return p.paint(ansiRed, "✗ "+text)
+	default:
+		return p.paint(ansiRed, "✗ "+text)
+	}
+}
+
+func (p *textPrinter) timestamp() string {
+	return p.paint(ansiDim, time.Now().Format("15:04:05"))
+}
+
+func (p *textPrinter) paint(color, text string) string {
+	if !p.color {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+func (p *textPrinter) printf(format string, args ...interface{}) {
+	p.mu.Lock()
+	fmt.Fprintf(p.w, format, args...)
+	p.mu.Unlock()
+}
+
+// round rounds the duration for display.
+func round(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d - d%time.Millisecond
+	}
+	return d - d%(100*time.Millisecond)
+}
+
+//
+// json printer.
+//
+
+// event defines a machine readable pipeline event.
+type event struct {
+	Type     string                `json:"type"`
+	Time     time.Time             `json:"time"`
+	Axis     string                `json:"axis,omitempty"`
+	Step     string                `json:"step,omitempty"`
+	Name     string                `json:"name,omitempty"`
+	Line     string                `json:"line,omitempty"`
+	State    pipeline.StepState    `json:"state,omitempty"`
+	Reason   string                `json:"reason,omitempty"`
+	ExitCode *int                  `json:"exit_code,omitempty"`
+	Duration float64               `json:"duration,omitempty"`
+	Usage    *backend.Usage        `json:"usage,omitempty"`
+	Steps    []pipeline.StepStatus `json:"steps,omitempty"`
+	Error    string                `json:"error,omitempty"`
+}
+
+// Event types.
+const (
+	eventStarted  = "step_started"
+	eventFinished = "step_finished"
+	eventSkipped  = "step_skipped"
+	eventLog      = "log"
+	eventSummary  = "summary"
+)
+
+type jsonPrinter struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	axis    string
+	started map[string]time.Time
+}
+
+// newJSONPrinter returns a printer that writes one json event per line.
+// The events are labeled with the matrix axis, if any.
+func newJSONPrinter(w io.Writer, axis string) *jsonPrinter {
+	return &jsonPrinter{
+		enc:     json.NewEncoder(w),
+		axis:    axis,
+		started: map[string]time.Time{},
+	}
+}
+
+func (p *jsonPrinter) Trace(state *pipeline.State) error {
+	proc := state.Pipeline.Step
+	status := state.Status
+	e := &event{
+		Time: time.Now(),
+		Step: stepName(proc),
+		Name: proc.Name,
+	}
+	switch {
+	case status.State == pipeline.StateSkipped:
+		e.Type = eventSkipped
+		e.Reason = status.Reason
+	case !state.Process.Exited:
+		setBuildStatus(state)
+		e.Type = eventStarted
+		p.mu.Lock()
+		p.started[proc.Name] = e.Time
+		p.mu.Unlock()
+	default:
+		e.Type = eventFinished
+		e.State = status.State
+		e.Reason = status.Reason
+		e.ExitCode = &status.ExitCode
+		e.Usage = state.Process.Usage
+		p.mu.Lock()
+		if started, ok := p.started[proc.Name]; ok {
+			e.Duration = e.Time.Sub(started).Seconds()
+		}
+		p.mu.Unlock()
+	}
+	p.emit(e)
+	return nil
+}
+
+func (p *jsonPrinter) Log(proc *backend.Step, rc multipart.Reader) error {
+	return scanLines(rc, func(text string) {
+		p.emit(&event{
+			Type: eventLog,
+			Time: time.Now(),
+			Step: stepName(proc),
+			Name: proc.Name,
+			Line: strings.TrimRight(text, "\r"),
+		})
+	})
+}
+
+func (p *jsonPrinter) Summary(status []pipeline.StepStatus, err error) {
+	e := &event{
+		Type:  eventSummary,
+		Time:  time.Now(),
+		Steps: status,
+		State: pipeline.StateSuccess,
+	}
+	if err != nil {
+		e.State = pipeline.StateFailure
+		e.Error = describe(err, status)
+	}
+	p.emit(e)
+}
+
+func (p *jsonPrinter) emit(e *event) {
+	e.Axis = p.axis
+	p.mu.Lock()
+	p.enc.Encode(e)
+	p.mu.Unlock()
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+	"github.com/cncd/pipeline/pipeline/frontend/yaml"
+	"github.com/cncd/pipeline/pipeline/frontend/yaml/linter"
+	"github.com/cncd/pipeline/pipeline/frontend/yaml/matrix"
+
+	"github.com/urfave/cli"
+)
+
+var runCommand = cli.Command{
+	Name:      "run",
+	Usage:     "lint, compile and execute the yaml file for each matrix axis",
+	ArgsUsage: "[.pipeline.yml]",
+	Action:    runAction,
+	Flags: append(append([]cli.Flag{
+		cli.IntFlag{
+			Name:  "parallel",
+			Usage: "number of matrix axes executed in parallel, sharing the same workspace",
+			Value: 1,
+		},
+		cli.BoolFlag{
+			Name: "trusted",
+		},
+	}, withoutFlag(compilerFlags, "local")...), executeFlags...),
+}
+
+// axisResult defines the result of a matrix axis execution.
+type axisResult struct {
+	label    string
+	err      error
+	duration time.Duration
+}
+
+func runAction(c *cli.Context) error {
+	file := c.Args().First()
+	if file == "" {
+		file = ".pipeline.yml"
+	}
+
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	axes, err := matrix.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if len(axes) == 0 {
+		axes = []matrix.Axis{nil}
+	}
+
+	metadata := metadataFromContext(c)
+	inferMetadata(&metadata, filepath.Dir(file))
+
+	// every axis is linted and compiled before the first axis is
+	// executed, so that configuration errors are reported early.
+	var (
+		labels   = make([]string, len(axes))
+		compiled = make([]func() error, len(axes))
+	)
+	for i, axis := range axes {
+		conf, err := yaml.ParseString(substitute(string(raw), axis))
+		if err != nil {
+			return fmt.Errorf("Error: axis %d: %s", i+1, err)
+		}
+		err = linter.New(
+			linter.WithTrusted(
+				c.Bool("trusted"),
+			),
+		).Lint(conf)
+		if err != nil {
+			return fmt.Errorf("Error: axis %d: %s", i+1, err)
+		}
+
+		m := metadata
+		m.Job = frontend.Job{Number: i + 1, Matrix: axis}
+
+		// the yaml file directory is mounted as the workspace of every
+		// axis, so axes executed in parallel share the same files.
+		config := compile(c, file, conf, m, fmt.Sprintf("%s_%d", c.String("prefix"), i+1), true)
+		label := axisLabel(axis)
+		labels[i] = label
+		compiled[i] = func() error {
+			return execute(c, config, label)
+		}
+	}
+
+	results := make([]axisResult, len(axes))
+	parallel := c.Int("parallel")
+	if parallel < 1 {
+		parallel = 1
+	}
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, parallel)
+	)
+	for i := range compiled {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			start := time.Now()
+			err := compiled[i]()
+			results[i] = axisResult{
+				label:    labels[i],
+				err:      err,
+				duration: time.Since(start),
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return summarizeAxes(c, results)
+}
+
+// summarizeAxes prints the result of each matrix axis, and returns an
+// error if any axis failed.
+func summarizeAxes(c *cli.Context, results []axisResult) error {
+	var failed int
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+		}
+	}
+
+	if len(results) > 1 && c.String("output") != "json" {
+		fmt.Println()
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "AXIS\tSTATUS\tDURATION")
+		for _, result := range results {
+			status := "success"
+			if result.err != nil {
+				status = "failure"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", result.label, status, result.duration.Round(time.Millisecond))
+		}
+		tw.Flush()
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("Error: %d of %d axes failed", failed, len(results))
+	}
+	return nil
+}
+
+// withoutFlag returns the flags except the named flag. The run command
+// always executes the local checkout, so it does not accept the local
+// flag.
+func withoutFlag(flags []cli.Flag, name string) []cli.Flag {
+	var filtered []cli.Flag
+	for _, flag := range flags {
+		if flag.GetName() != name {
+			filtered = append(filtered, flag)
+		}
+	}
+	return filtered
+}
+
+// axisLabel returns the matrix axis as a sorted list of environment
+// variables.
+func axisLabel(axis matrix.Axis) string {
+	var envs []string
+	for k, v := range axis {
+		envs = append(envs, k+"="+v)
+	}
+	sort.Strings(envs)
+	return strings.Join(envs, " ")
+}
+
+var reVariable = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// substitute replaces the ${KEY} variables in the yaml file with the
+// matrix axis values. Variables that are not defined by the axis are
+// left unchanged.
+func substitute(raw string, axis matrix.Axis) string {
+	return reVariable.ReplaceAllStringFunc(raw, func(s string) string {
+		if v, ok := axis[reVariable.FindStringSubmatch(s)[1]]; ok {
+			return v
+		}
+		return s
+	})
+}
+
+// inferMetadata fills the commit and repository metadata, not provided
+// by flags, from the git checkout in the directory.
+func inferMetadata(m *frontend.Metadata, dir string) {
+	if m.Curr.Event == "" {
+		m.Curr.Event = frontend.EventPush
+	}
+	commit := &m.Curr.Commit
+	if commit.Branch == "" {
+		commit.Branch = git(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	}
+	if commit.Sha == "" {
+		commit.Sha = git(dir, "rev-parse", "HEAD")
+	}
+	if commit.Ref == "" && commit.Branch != "" && commit.Branch != "HEAD" {
+		commit.Ref = "refs/heads/" + commit.Branch
+	}
+	if commit.Author.Name == "" && commit.Author.Email == "" && commit.Message == "" {
+		parts := strings.SplitN(git(dir, "log", "-1", "--format=%an%n%ae%n%B"), "\n", 3)
+		if len(parts) == 3 {
+			commit.Author.Name = parts[0]
+			commit.Author.Email = parts[1]
+			commit.Message = strings.TrimSpace(parts[2])
+		}
+	}
+	if m.Repo.Remote == "" {
+		m.Repo.Remote = git(dir, "config", "--get", "remote.origin.url")
+	}
+}
+
+// git runs the git command in the directory and returns the trimmed
+// output, or an empty string if the command fails.
+func git(dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
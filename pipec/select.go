@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cncd/pipeline/pipeline"
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+// reasonNotSelected is the reason steps excluded from the execution
+// are skipped.
+const reasonNotSelected = "not selected"
+
+// setupStage matches the names of the clone and restore cache stages
+// added by the compiler.
+var setupStage = regexp.MustCompile(`_(clone(_[0-9]+)?|restore_cache)$`)
+
+// selectSteps returns a copy of the configuration that only includes
+// the selected steps, and the excluded steps. Steps are selected by
+// alias: only includes the listed steps, skip excludes the listed
+// steps, and from includes the named step and every step after it.
+// Services, and the clone and restore cache steps, are included unless
+// explicitly skipped, and the volumes and networks are unchanged.
+func selectSteps(config *backend.Config, only, skip []string, from string) (*backend.Config, []*backend.Step, error) {
+	only, skip = splitNames(only), splitNames(skip)
+	if len(only) != 0 && from != "" {
+		return nil, nil, fmt.Errorf("Error: the --only and --from flags cannot be combined")
+	}
+
+	names := map[string]bool{}
+	for _, stage := range config.Stages {
+		for _, proc := range stage.Steps {
+			names[stepName(proc)] = true
+		}
+	}
+	for _, name := range append(append([]string{from}, only...), skip...) {
+		if name != "" && !names[name] {
+			return nil, nil, fmt.Errorf("Error: no such step %q, expected one of %s", name, strings.Join(stepNames(config), ", "))
+		}
+	}
+
+	onlySet, skipSet := toSet(only), toSet(skip)
+	selected := *config
+	selected.Stages = nil
+
+	var excluded []*backend.Step
+	reached := from == ""
+	for _, stage := range config.Stages {
+		copied := *stage
+		copied.Steps = nil
+		setup := setupStage.MatchString(stage.Name)
+		for _, proc := range stage.Steps {
+			name := stepName(proc)
+			if name == from {
+				reached = true
+			}
+			include := true
+			switch {
+			case skipSet[name]:
+				include = false
+			case proc.Detached, setup:
+				// services are started, and the workspace is cloned,
+				// so that the selected steps can use them.
+			case len(onlySet) != 0:
+				include = onlySet[name]
+			default:
+				include = reached
+			}
+			if include {
+				copied.Steps = append(copied.Steps, proc)
+			} else {
+				excluded = append(excluded, proc)
+			}
+		}
+		if len(copied.Steps) != 0 {
+			selected.Stages = append(selected.Stages, &copied)
+		}
+	}
+	return &selected, excluded, nil
+}
+
+// traceExcluded reports the excluded steps as skipped through the
+// tracer.
+func traceExcluded(tracer pipeline.Tracer, excluded []*backend.Step) {
+	for _, proc := range excluded {
+		state := new(pipeline.State)
+		state.Pipeline.Step = proc
+		state.Process = &backend.State{Exited: true}
+		state.Status = excludedStatus(proc)
+		tracer.Trace(state)
+	}
+}
+
+// mergeStatus returns the status of every step in the configuration,
+// including the excluded steps, in execution order.
+func mergeStatus(config *backend.Config, status []pipeline.StepStatus) []pipeline.StepStatus {
+	index := map[string]pipeline.StepStatus{}
+	for _, s := range status {
+		index[s.Name] = s
+	}
+	var merged []pipeline.StepStatus
+	for _, stage := range config.Stages {
+		for _, proc := range stage.Steps {
+			if s, ok := index[proc.Name]; ok {
+				merged = append(merged, s)
+			} else {
+				merged = append(merged, excludedStatus(proc))
+			}
+		}
+	}
+	return merged
+}
+
+func excludedStatus(proc *backend.Step) pipeline.StepStatus {
+	return pipeline.StepStatus{
+		Name:   proc.Name,
+		Alias:  proc.Alias,
+		State:  pipeline.StateSkipped,
+		Reason: reasonNotSelected,
+	}
+}
+
+// stepNames returns the step aliases in execution order.
+func stepNames(config *backend.Config) []string {
+	var names []string
+	for _, stage := range config.Stages {
+		for _, proc := range stage.Steps {
+			names = append(names, stepName(proc))
+		}
+	}
+	return names
+}
+
+// splitNames splits comma separated step names.
+func splitNames(list []string) []string {
+	var names []string
+	for _, item := range list {
+		for _, name := range strings.Split(item, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func toSet(list []string) map[string]bool {
+	set := map[string]bool{}
+	for _, item := range list {
+		set[item] = true
+	}
+	return set
+}
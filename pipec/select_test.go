@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/frontend/yaml"
+	"github.com/cncd/pipeline/pipeline/frontend/yaml/compiler"
+)
+
+func TestSelectStepsClone(t *testing.T) {
+	conf, err := yaml.ParseString(`
+pipeline:
+  build:
+    image: golang
+  test:
+    image: golang
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := compiler.New(compiler.WithPrefix("pipeline")).Compile(conf)
+
+	selected, _, err := selectSteps(config, []string{"build"}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stepNames(selected), []string{"clone", "build"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want clone step included with the selected steps %v, got %v", want, got)
+	}
+
+	selected, _, err = selectSteps(config, nil, []string{"clone"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stepNames(selected), []string{"test"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want clone step excluded when skipped %v, got %v", want, got)
+	}
+}
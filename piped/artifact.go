@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/multipart"
+	"github.com/cncd/pipeline/pipeline/rpc"
+)
+
+// uploadArtifact uploads the multipart part as a pipeline artifact. The
+// artifact is typed by the part content type, and the summary headers of
+// the part, such as X-Tests-Failed or X-Covered, are uploaded as the
+// artifact metadata.
+func (r *runner) uploadArtifact(id, proc string, part multipart.Part) {
+	// TODO should be configurable
+	limitedPart := io.LimitReader(part, maxFileUpload)
+	file := &rpc.File{}
+	file.Mime = part.Header().Get("Content-Type")
+	file.Proc = proc
+	file.Name = part.FileName()
+	file.Data, _ = ioutil.ReadAll(limitedPart)
+	file.Size = len(file.Data)
+	file.Time = time.Now().Unix()
+	file.Meta = artifactMeta(part.Header())
+
+	if err := r.client.Upload(context.Background(), id, file); err != nil {
+		r.logf("pipeline: cannot upload artifact: %s: %s: %s", id, file.Mime, err)
+	} else {
+		r.logf("pipeline: finish uploading artifact: %s: step %s: %s", file.Mime, id, proc)
+	}
+}
+
+// artifactMeta returns the X- prefixed headers of the part.
+func artifactMeta(header textproto.MIMEHeader) map[string]string {
+	meta := map[string]string{}
+	for key := range header {
+		if strings.HasPrefix(key, "X-") {
+			meta[key] = header.Get(key)
+		}
+	}
+	return meta
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// drain handles the termination signals. The first signal stops the
+// agent from requesting new pipelines, and the running pipelines are
+// given until the drain timeout to complete. The running pipelines are
+// cancelled when the timeout expires or a second signal is received.
+// A zero timeout waits for the running pipelines indefinitely.
+func drain(stop, abort func(), timeout time.Duration) {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
+
+	<-c
+	log.Printf("termination signal received, waiting for running pipelines to complete")
+	stop()
+
+	var deadline <-chan time.Time
+	if timeout != 0 {
+		deadline = time.After(timeout)
+	}
+	select {
+	case <-c:
+		log.Printf("second termination signal received, cancelling running pipelines")
+	case <-deadline:
+		log.Printf("drain timeout expired, cancelling running pipelines")
+	}
+	abort()
+}
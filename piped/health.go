@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/rpc"
+
+	dockerclient "github.com/docker/docker/client"
+	"github.com/tevino/abool"
+)
+
+// healthTimeout is the maximum time spent on each health check.
+const healthTimeout = 5 * time.Second
+
+// health reports whether the agent can reach the server and the docker
+// daemon, and whether it is ready to execute pipelines.
+type health struct {
+	server   rpc.Health // optional
+	docker   dockerclient.APIClient
+	draining *abool.AtomicBool
+}
+
+// healthz reports the server and docker daemon connectivity.
+func (h *health) healthz(w http.ResponseWriter, r *http.Request) {
+	checks := h.check(r.Context())
+	writeChecks(w, checks)
+}
+
+// readyz reports the server and docker daemon connectivity, and fails
+// when the agent no longer accepts pipelines because it is draining.
+func (h *health) readyz(w http.ResponseWriter, r *http.Request) {
+	checks := h.check(r.Context())
+	if h.draining.IsSet() {
+		checks["agent"] = "draining"
+	} else {
+		checks["agent"] = "ok"
+	}
+	writeChecks(w, checks)
+}
+
+// check runs the health checks and returns the result of each check,
+// which is ok if the check passed.
+func (h *health) check(ctx context.Context) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+
+	checks := map[string]string{}
+	if _, err := h.docker.Ping(ctx); err != nil {
+		checks["docker"] = err.Error()
+	} else {
+		checks["docker"] = "ok"
+	}
+	if h.server != nil {
+		ok, err := h.server.Check(ctx)
+		switch {
+		case err != nil:
+			checks["server"] = err.Error()
+		case !ok:
+			checks["server"] = "not serving"
+		default:
+			checks["server"] = "ok"
+		}
+	}
+	return checks
+}
+
+// serve serves the metrics and the health endpoints. The health
+// endpoints are served with the metrics when no health address is
+// configured.
+func serve(metricsAddr, healthAddr string, h *health) {
+	muxes := map[string]*http.ServeMux{}
+	mux := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if metricsAddr != "" {
+		mux(metricsAddr).Handle("/metrics", registry)
+	}
+	if healthAddr == "" {
+		healthAddr = metricsAddr
+	}
+	if healthAddr != "" {
+		mux(healthAddr).HandleFunc("/healthz", h.healthz)
+		mux(healthAddr).HandleFunc("/readyz", h.readyz)
+	}
+	for addr, handler := range muxes {
+		go func(addr string, handler http.Handler) {
+			if err := http.ListenAndServe(addr, handler); err != nil {
+				log.Printf("http: cannot serve on %s: %s", addr, err)
+			}
+		}(addr, handler)
+	}
+}
+
+// helper function writes the check results as json, with a service
+// unavailable status if any of the checks failed.
+func writeChecks(w http.ResponseWriter, checks map[string]string) {
+	status := http.StatusOK
+	for _, result := range checks {
+		if result != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(checks)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/rpc"
+
+	dockerclient "github.com/docker/docker/client"
+	"github.com/urfave/cli"
+)
+
+// agentFilter returns the filter used to request pipelines from the
+// queue. The filter labels combine the labels detected from the docker
+// daemon, the platform and the labels configured by the user, in order
+// of increasing precedence.
+func agentFilter(c *cli.Context, docker dockerclient.APIClient) (rpc.Filter, error) {
+	labels, err := parseLabels(c.StringSlice("label"))
+	if err != nil {
+		return rpc.NoFilter, err
+	}
+
+	filter := rpc.Filter{
+		Labels: detectLabels(docker),
+		Expr:   c.String("filter"),
+	}
+	filter.Labels["platform"] = c.String("platform")
+	for key, value := range labels {
+		filter.Labels[key] = value
+	}
+	return filter, nil
+}
+
+// parseLabels parses a list of key=value labels.
+func parseLabels(list []string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, label := range list {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid label %q, expected key=value", label)
+		}
+		labels[parts[0]] = parts[1]
+	}
+	return labels, nil
+}
+
+// detectLabels returns the labels describing the docker daemon and its
+// host. Detection failures are logged and result in no labels.
+func detectLabels(docker dockerclient.APIClient) map[string]string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	labels := map[string]string{}
+	info, err := docker.Info(ctx)
+	if err != nil {
+		log.Printf("labels: cannot detect docker labels: %s", err)
+		return labels
+	}
+	labels["docker.version"] = info.ServerVersion
+	labels["kernel.version"] = info.KernelVersion
+	labels["cpu.count"] = strconv.Itoa(info.NCPU)
+	labels["memory.total"] = strconv.FormatInt(info.MemTotal, 10)
+	return labels
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"io"
-	"io/ioutil"
 	"log"
 	"math"
 	"net/url"
@@ -16,13 +15,16 @@ import (
 	"github.com/cncd/pipeline/pipeline"
 	"github.com/cncd/pipeline/pipeline/backend"
 	"github.com/cncd/pipeline/pipeline/backend/docker"
-	"github.com/cncd/pipeline/pipeline/interrupt"
 	"github.com/cncd/pipeline/pipeline/multipart"
+	"github.com/cncd/pipeline/pipeline/otel"
 	"github.com/cncd/pipeline/pipeline/rpc"
+	"github.com/cncd/pipeline/pipeline/rpc/spool"
 
+	dockerclient "github.com/docker/docker/client"
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/tevino/abool"
 	"github.com/urfave/cli"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -45,6 +47,26 @@ func main() {
 			Name:   "token",
 			EnvVar: "PIPED_TOKEN,PIPED_SECRET",
 		},
+		cli.StringFlag{
+			Name:   "tls-ca",
+			EnvVar: "PIPED_TLS_CA",
+			Usage:  "certificate authority bundle used to verify the server",
+		},
+		cli.StringFlag{
+			Name:   "tls-cert",
+			EnvVar: "PIPED_TLS_CERT",
+			Usage:  "client certificate presented to the server",
+		},
+		cli.StringFlag{
+			Name:   "tls-key",
+			EnvVar: "PIPED_TLS_KEY",
+			Usage:  "client certificate key",
+		},
+		cli.StringFlag{
+			Name:   "tls-server-name",
+			EnvVar: "PIPED_TLS_SERVER_NAME",
+			Usage:  "server name used to verify the server certificate",
+		},
 		cli.DurationFlag{
 			Name:   "backoff",
 			EnvVar: "PIPED_BACKOFF",
@@ -65,9 +87,81 @@ func main() {
 			EnvVar: "PIPED_UPLOAD_LIMIT",
 			Value:  math.MaxInt32,
 		},
+		cli.StringFlag{
+			Name:   "agent-id",
+			EnvVar: "PIPED_AGENT_ID",
+		},
+		cli.DurationFlag{
+			Name:   "prune-interval",
+			EnvVar: "PIPED_PRUNE_INTERVAL",
+		},
+		cli.DurationFlag{
+			Name:   "prune-max-age",
+			EnvVar: "PIPED_PRUNE_MAX_AGE",
+			Usage:  "remove resources older than the maximum age, including pipelines still running on other agents",
+		},
+		cli.BoolFlag{
+			Name:   "service-fail",
+			EnvVar: "PIPED_SERVICE_FAIL",
+			Usage:  "fail the pipeline when a service exits unsuccessfully",
+		},
+		cli.StringFlag{
+			Name:   "tracing-endpoint",
+			EnvVar: "PIPED_TRACING_ENDPOINT",
+			Usage:  "opentelemetry collector otlp/http endpoint",
+		},
+		cli.StringFlag{
+			Name:   "metrics-addr",
+			EnvVar: "PIPED_METRICS_ADDR",
+			Usage:  "serve prometheus metrics at /metrics on this address",
+		},
+		cli.StringSliceFlag{
+			Name:   "label",
+			EnvVar: "PIPED_LABELS",
+			Usage:  "agent label in key=value format, may be repeated",
+		},
+		cli.StringFlag{
+			Name:   "filter",
+			EnvVar: "PIPED_FILTER",
+			Usage:  "expression used by the server to select pipelines",
+		},
+		cli.StringFlag{
+			Name:   "health-addr",
+			EnvVar: "PIPED_HEALTH_ADDR",
+			Usage:  "serve /healthz and /readyz on this address, defaults to the metrics address",
+		},
+		cli.StringFlag{
+			Name:   "health-endpoint",
+			EnvVar: "PIPED_HEALTH_ENDPOINT",
+			Usage:  "grpc address of the server health service",
+		},
+		cli.DurationFlag{
+			Name:   "drain-timeout",
+			EnvVar: "PIPED_DRAIN_TIMEOUT",
+			Usage:  "time running pipelines are given to complete on shutdown, zero waits indefinitely",
+			Value:  time.Hour,
+		},
+		cli.StringFlag{
+			Name:   "spool-dir",
+			EnvVar: "PIPED_SPOOL_DIR",
+			Usage:  "directory where updates, logs and artifacts are spooled until delivered",
+		},
+		cli.DurationFlag{
+			Name:   "spool-flush-timeout",
+			EnvVar: "PIPED_SPOOL_FLUSH_TIMEOUT",
+			Usage:  "time spooled calls are given to be delivered on shutdown",
+			Value:  time.Minute,
+		},
+		cli.IntFlag{
+			Name:   "max-procs",
+			EnvVar: "PIPED_MAX_PROCS",
+			Usage:  "number of pipelines executed concurrently",
+			Value:  1,
+		},
 	}
 	app.Commands = []cli.Command{
 		onceCommand,
+		pruneCommand,
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -82,14 +176,11 @@ func start(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	filter := rpc.Filter{
-		Labels: map[string]string{
-			"platform": c.String("platform"),
-		},
+	certs, err := tlsCertificates(c)
+	if err != nil {
+		return err
 	}
-
-	client, err := rpc.NewClient(
-		endpoint.String(),
+	opts := []rpc.Option{
 		rpc.WithRetryLimit(
 			c.Int("retry-limit"),
 		),
@@ -99,56 +190,172 @@ func start(c *cli.Context) error {
 		rpc.WithToken(
 			c.String("token"),
 		),
-	)
+		rpc.WithReconnectFunc(observeReconnect),
+	}
+	if certs != nil {
+		opts = append(opts, rpc.WithTLS(certs, c.String("tls-server-name")))
+	}
+	client, err := rpc.NewClient(endpoint.String(), opts...)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	sigterm := abool.New()
-	ctx := context.Background()
-	ctx = interrupt.WithContextFunc(ctx, func() {
-		println("ctrl+c received, terminating process")
-		sigterm.Set()
-	})
+	dockerClient, err := dockerclient.NewEnvClient()
+	if err != nil {
+		return err
+	}
+	defer dockerClient.Close()
 
-	for {
-		if sigterm.IsSet() {
-			return nil
+	filter, err := agentFilter(c, dockerClient)
+	if err != nil {
+		return err
+	}
+	log.Printf("agent labels: %v", filter.Labels)
+
+	// the first termination signal stops the workers from requesting
+	// new pipelines, and the running pipelines are aborted when the
+	// drain timeout expires or a second signal is received.
+	draining := abool.New()
+	ctx, stop := context.WithCancel(context.Background())
+	abort, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go drain(func() {
+		draining.Set()
+		stop()
+	}, cancel, c.Duration("drain-timeout"))
+
+	h := &health{
+		docker:   dockerClient,
+		draining: draining,
+	}
+	if addr := c.String("health-endpoint"); addr != "" {
+		dialOpt := grpc.WithInsecure()
+		if certs != nil {
+			dialOpt = grpc.WithTransportCredentials(
+				rpc.NewGrpcCredentials(certs, c.String("tls-server-name")),
+			)
 		}
-		if err := run(ctx, client, filter); err != nil {
+		conn, err := grpc.Dial(addr, dialOpt)
+		if err != nil {
 			return err
 		}
+		defer conn.Close()
+		h.server = rpc.NewGrpcHealthClient(conn)
 	}
+	serve(c.String("metrics-addr"), c.String("health-addr"), h)
+
+	peer := instrument(client, endpoint)
+	if dir := c.String("spool-dir"); dir != "" {
+		s, err := spool.New(peer, dir)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		// calls spooled by a previous run are delivered first, and the
+		// spool is flushed before exiting unless the agent is aborted or
+		// the flush timeout expires, for example because the server is
+		// unreachable. Calls not delivered are kept for the next run.
+		sctx, scancel := context.WithCancel(context.Background())
+		defer scancel()
+		go func() {
+			if err := s.Run(sctx); err != nil && err != context.Canceled {
+				log.Println(err)
+			}
+		}()
+		defer func() {
+			log.Printf("spool: delivering spooled calls")
+			fctx, fcancel := context.WithTimeout(abort, c.Duration("spool-flush-timeout"))
+			defer fcancel()
+			if err := s.Flush(fctx); err != nil {
+				log.Printf("spool: calls not delivered are kept in %s", dir)
+			}
+		}()
+		peer = s
+	}
+
+	r := &runner{
+		client: peer,
+		filter: filter,
+		agent:  agentID(c),
+		policy: servicePolicy(c),
+		traces: traceExporter(c),
+		docker: dockerClient,
+		abort:  abort,
+	}
+	if interval := c.Duration("prune-interval"); interval != 0 {
+		go sweep(ctx, r.agent, interval, c.Duration("prune-max-age"))
+	}
+
+	return r.work(ctx, draining, c.Int("max-procs"))
+}
+
+// runner executes pipelines received from the server.
+type runner struct {
+	client rpc.Peer
+	filter rpc.Filter
+	agent  string
+	policy pipeline.ServicePolicy
+	traces otel.Exporter
+	docker dockerclient.APIClient
+	abort  context.Context
+	logger *log.Logger
 }
 
-func run(ctx context.Context, client rpc.Peer, filter rpc.Filter) error {
-	log.Println("pipeline: request next execution")
+func (r *runner) run(ctx context.Context) error {
+	client := r.client
+	r.logf("pipeline: request next execution")
 
 	// get the next job from the queue
-	work, err := client.Next(ctx, filter)
+	work, err := client.Next(ctx, r.filter)
+	observePoll(work, err)
 	if err != nil {
 		return err
 	}
 	if work == nil {
 		return nil
 	}
-	log.Printf("pipeline: received next execution: %s", work.ID)
+	r.logf("pipeline: received next execution: %s", work.ID)
 	if os.Getenv("SUICIDE_MODE") != "" {
 		os.Exit(1)
 	}
-	// new docker engine
-	engine, err := docker.NewEnv()
-	if err != nil {
-		return err
+	running.add(work.ID)
+	defer running.remove(work.ID)
+
+	pipelinesStarted.Inc()
+	pipelinesRunning.Add(1)
+	defer pipelinesRunning.Add(-1)
+
+	opts := []docker.Option{
+		docker.WithPipeline(work.ID),
+		docker.WithAgent(r.agent),
+		docker.WithObserver(observePhase),
+	}
+
+	// the pipeline execution is traced when a collector is configured,
+	// joining the trace started by the server.
+	var spans *otel.Tracer
+	if r.traces != nil {
+		spans = otel.New(work.Config,
+			otel.WithExporter(r.traces),
+			otel.WithTraceID(work.TraceID),
+			otel.WithPipeline(work.ID),
+		)
+		opts = append(opts, docker.WithObserver(spans.Phase))
 	}
 
+	// new docker engine, sharing the docker client with other workers
+	engine := docker.New(r.docker, opts...)
+
 	timeout := time.Hour
 	if minutes := work.Timeout; minutes != 0 {
 		timeout = time.Duration(minutes) * time.Minute
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// the pipeline is not cancelled when the agent stops requesting
+	// work, only when the agent is aborted.
+	ctx, cancel := context.WithTimeout(r.abort, timeout)
 	defer cancel()
 
 	cancelled := abool.New()
@@ -156,10 +363,10 @@ func run(ctx context.Context, client rpc.Peer, filter rpc.Filter) error {
 		werr := client.Wait(ctx, work.ID)
 		if werr != nil {
 			cancelled.SetTo(true) // TODO verify error is really an error
-			log.Printf("pipeline: cancel signal received: %s: %s", work.ID, werr)
+			r.logf("pipeline: cancel signal received: %s: %s", work.ID, werr)
 			cancel()
 		} else {
-			log.Printf("pipeline: cancel channel closed: %s", work.ID)
+			r.logf("pipeline: cancel channel closed: %s", work.ID)
 		}
 	}()
 
@@ -167,10 +374,10 @@ func run(ctx context.Context, client rpc.Peer, filter rpc.Filter) error {
 		for {
 			select {
 			case <-ctx.Done():
-				log.Printf("pipeline: cancel ping loop: %s", work.ID)
+				r.logf("pipeline: cancel ping loop: %s", work.ID)
 				return
 			case <-time.After(time.Minute):
-				log.Printf("pipeline: ping queue: %s", work.ID)
+				r.logf("pipeline: ping queue: %s", work.ID)
 				client.Extend(ctx, work.ID)
 			}
 		}
@@ -180,7 +387,7 @@ func run(ctx context.Context, client rpc.Peer, filter rpc.Filter) error {
 	state.Started = time.Now().Unix()
 	err = client.Init(context.Background(), work.ID, state)
 	if err != nil {
-		log.Printf("pipeline: error signaling pipeline init: %s: %s", work.ID, err)
+		r.logf("pipeline: error signaling pipeline init: %s: %s", work.ID, err)
 	}
 
 	var uploads sync.WaitGroup
@@ -211,52 +418,58 @@ func run(ctx context.Context, client rpc.Peer, filter rpc.Filter) error {
 		file.Time = time.Now().Unix()
 
 		if serr := client.Upload(context.Background(), work.ID, file); serr != nil {
-			log.Printf("pipeline: cannot upload logs: %s: %s: %s", work.ID, file.Mime, serr)
+			r.logf("pipeline: cannot upload logs: %s: %s: %s", work.ID, file.Mime, serr)
 		} else {
-			log.Printf("pipeline: finish uploading logs: %s: step %s: %s", file.Mime, work.ID, proc.Alias)
+			r.logf("pipeline: finish uploading logs: %s: step %s: %s", file.Mime, work.ID, proc.Alias)
 		}
 
 		defer func() {
-			log.Printf("pipeline: finish uploading logs: %s: step %s", work.ID, proc.Alias)
+			r.logf("pipeline: finish uploading logs: %s: step %s", work.ID, proc.Alias)
 			uploads.Done()
 		}()
 
-		part, rerr = rc.NextPart()
-		if rerr != nil {
-			return nil
-		}
-		// TODO should be configurable
-		limitedPart = io.LimitReader(part, maxFileUpload)
-		file = &rpc.File{}
-		file.Mime = part.Header().Get("Content-Type")
-		file.Proc = proc.Alias
-		file.Name = part.FileName()
-		file.Data, _ = ioutil.ReadAll(limitedPart)
-		file.Size = len(file.Data)
-		file.Time = time.Now().Unix()
-
-		if serr := client.Upload(context.Background(), work.ID, file); serr != nil {
-			log.Printf("pipeline: cannot upload artifact: %s: %s: %s", work.ID, file.Mime, serr)
-		} else {
-			log.Printf("pipeline: finish uploading artifact: %s: step %s: %s", file.Mime, work.ID, proc.Alias)
+		// the remaining parts are artifacts, such as coverage and test
+		// reports, typed by their content type.
+		for {
+			part, rerr = rc.NextPart()
+			if rerr != nil {
+				return nil
+			}
+			r.uploadArtifact(work.ID, proc.Alias, part)
 		}
-		return nil
 	})
 
+	timer := newStepTimer()
 	defaultTracer := pipeline.TraceFunc(func(state *pipeline.State) error {
+		if spans != nil {
+			spans.Trace(state)
+		}
+		timer.observe(state.Status)
+		// skipped steps are not reported, the server marks steps
+		// that never ran when the pipeline is done.
+		if state.Status.State == pipeline.StateSkipped {
+			return nil
+		}
 		procState := rpc.State{
 			Proc:     state.Pipeline.Step.Alias,
 			Exited:   state.Process.Exited,
 			ExitCode: state.Process.ExitCode,
-			Started:  time.Now().Unix(), // TODO do not do this
-			Finished: time.Now().Unix(),
+			Started:  state.Status.Started,
+			Finished: state.Status.Finished,
+		}
+		if state.Status.Reason == pipeline.ReasonAllowed {
+			procState.Error = "failed (allowed)"
+			r.logf("pipeline: step failed (allowed): %s: %s", work.ID, procState.Proc)
 		}
 		defer func() {
 			if uerr := client.Update(context.Background(), work.ID, procState); uerr != nil {
-				log.Printf("Pipeine: error updating pipeline step status: %s: %s: %s", work.ID, procState.Proc, uerr)
+				r.logf("Pipeine: error updating pipeline step status: %s: %s: %s", work.ID, procState.Proc, uerr)
 			}
 		}()
 		if state.Process.Exited {
+			if state.Process.Usage != nil {
+				r.uploadUsage(work.ID, procState.Proc, state.Process.Usage)
+			}
 			return nil
 		}
 		if state.Pipeline.Step.Environment == nil {
@@ -282,6 +495,7 @@ func run(ctx context.Context, client rpc.Peer, filter rpc.Filter) error {
 		pipeline.WithLogger(defaultLogger),
 		pipeline.WithTracer(defaultTracer),
 		pipeline.WithEngine(engine),
+		pipeline.WithServicePolicy(r.policy),
 	).Run()
 
 	state.Finished = time.Now().Unix()
@@ -294,21 +508,58 @@ func run(ctx context.Context, client rpc.Peer, filter rpc.Filter) error {
 		if xerr, ok := err.(*pipeline.OomError); ok {
 			state.ExitCode = xerr.Code
 		}
-		if cancelled.IsSet() {
+		if cancelled.IsSet() || r.abort.Err() != nil {
 			state.ExitCode = 130
 		} else if state.ExitCode == 0 {
 			state.ExitCode = 1
 		}
 	}
 
-	log.Printf("pipeline: execution complete: %s", work.ID)
+	r.logf("pipeline: execution complete: %s", work.ID)
+	observeOutcome(err, cancelled.IsSet() || r.abort.Err() != nil)
+
+	if spans != nil {
+		tctx, tcancel := context.WithTimeout(context.Background(), time.Second*30)
+		if terr := spans.End(tctx, err); terr != nil {
+			r.logf("pipeline: cannot export traces: %s: %s", work.ID, terr)
+		}
+		tcancel()
+	}
 
 	uploads.Wait()
 
 	err = client.Done(context.Background(), work.ID, state)
 	if err != nil {
-		log.Printf("Pipeine: error signaling pipeline done: %s: %s", work.ID, err)
+		r.logf("Pipeine: error signaling pipeline done: %s: %s", work.ID, err)
 	}
 
 	return nil
 }
+
+// traceExporter returns the exporter used to send the pipeline traces
+// to the collector, or nil if tracing is disabled.
+func traceExporter(c *cli.Context) otel.Exporter {
+	if endpoint := c.String("tracing-endpoint"); endpoint != "" {
+		return otel.NewHTTPExporter(endpoint, "piped")
+	}
+	return nil
+}
+
+// tlsCertificates returns the certificates used to connect to the
+// server with mutual tls, or nil if tls is not configured.
+func tlsCertificates(c *cli.Context) (*rpc.Certificates, error) {
+	ca, cert, key := c.String("tls-ca"), c.String("tls-cert"), c.String("tls-key")
+	if ca == "" && cert == "" && key == "" {
+		return nil, nil
+	}
+	return rpc.NewCertificates(ca, cert, key)
+}
+
+// servicePolicy returns the policy applied when a service exits
+// unsuccessfully.
+func servicePolicy(c *cli.Context) pipeline.ServicePolicy {
+	if c.Bool("service-fail") {
+		return pipeline.ServiceFail
+	}
+	return pipeline.ServiceWarn
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline"
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/backend/docker"
+	"github.com/cncd/pipeline/pipeline/metrics"
+	"github.com/cncd/pipeline/pipeline/rpc"
+)
+
+// durationBuckets are the histogram buckets, in seconds, used for step
+// durations and image pull durations.
+var durationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+
+var (
+	registry = metrics.NewRegistry()
+
+	queuePolls = registry.Counter(
+		"piped_queue_polls_total",
+		"Total number of requests for the next pipeline in the queue, by result.",
+		"result",
+	)
+	pipelinesStarted = registry.Counter(
+		"piped_pipelines_started_total",
+		"Total number of pipelines started.",
+	)
+	pipelinesCompleted = registry.Counter(
+		"piped_pipelines_completed_total",
+		"Total number of pipelines completed, by outcome.",
+		"outcome",
+	)
+	pipelinesRunning = registry.Gauge(
+		"piped_pipelines_running",
+		"Number of pipelines currently running.",
+	)
+	stepDuration = registry.Histogram(
+		"piped_step_duration_seconds",
+		"Duration of pipeline steps, by final state.",
+		durationBuckets,
+		"state",
+	)
+	pullDuration = registry.Histogram(
+		"piped_image_pull_duration_seconds",
+		"Duration of image pulls.",
+		durationBuckets,
+	)
+	rpcErrors = registry.Counter(
+		"piped_rpc_errors_total",
+		"Total number of failed rpc calls, by method and transport.",
+		"method", "transport",
+	)
+	uploadBytes = registry.Counter(
+		"piped_upload_bytes_total",
+		"Total number of bytes uploaded, by kind.",
+		"kind",
+	)
+	reconnects = registry.Counter(
+		"piped_reconnects_total",
+		"Total number of re-connects to the server, by result.",
+		"result",
+	)
+)
+
+// observePoll records the result of a request for the next pipeline.
+func observePoll(work *rpc.Pipeline, err error) {
+	switch {
+	case err != nil:
+		queuePolls.Inc("error")
+	case work == nil:
+		queuePolls.Inc("empty")
+	default:
+		queuePolls.Inc("received")
+	}
+}
+
+// observeOutcome records the outcome of a completed pipeline.
+func observeOutcome(err error, cancelled bool) {
+	outcome := "success"
+	switch err.(type) {
+	case nil:
+	case *pipeline.ExitError, *pipeline.OomError:
+		outcome = "failure"
+	default:
+		outcome = "error"
+	}
+	if cancelled {
+		outcome = "cancelled"
+	}
+	pipelinesCompleted.Inc(outcome)
+}
+
+// stepTimer measures the duration of the steps of a pipeline. The step
+// status only records whole seconds, so the duration is measured with
+// the monotonic clock from the time the step is traced as running.
+type stepTimer struct {
+	sync.Mutex
+	started map[string]time.Time
+}
+
+func newStepTimer() *stepTimer {
+	return &stepTimer{started: map[string]time.Time{}}
+}
+
+// observe records the duration of the step when it completes.
+func (t *stepTimer) observe(status pipeline.StepStatus) {
+	t.Lock()
+	defer t.Unlock()
+	switch {
+	case status.State == pipeline.StateRunning:
+		if _, ok := t.started[status.Name]; !ok {
+			t.started[status.Name] = time.Now()
+		}
+	case status.Done():
+		if started, ok := t.started[status.Name]; ok {
+			delete(t.started, status.Name)
+			stepDuration.Observe(time.Since(started).Seconds(), string(status.State))
+		}
+	}
+}
+
+// observePhase records the duration of image pulls. Its signature
+// matches the docker engine observer.
+func observePhase(proc *backend.Step, phase string, started time.Time, err error) {
+	if phase == docker.PhasePull && err == nil {
+		pullDuration.Observe(time.Since(started).Seconds())
+	}
+}
+
+// observeReconnect records the result of a re-connect to the server.
+func observeReconnect(err error) {
+	if err != nil {
+		reconnects.Inc("failure")
+	} else {
+		reconnects.Inc("success")
+	}
+}
+
+// instrument returns a peer that records failed calls and the number of
+// bytes uploaded. The transport is derived from the endpoint scheme.
+func instrument(peer rpc.Peer, endpoint *url.URL) rpc.Peer {
+	transport := "websocket"
+	if endpoint.Scheme != "ws" && endpoint.Scheme != "wss" {
+		transport = endpoint.Scheme
+	}
+	return &instrumentedPeer{peer, transport}
+}
+
+type instrumentedPeer struct {
+	peer      rpc.Peer
+	transport string
+}
+
+func (p *instrumentedPeer) Next(c context.Context, f rpc.Filter) (*rpc.Pipeline, error) {
+	work, err := p.peer.Next(c, f)
+	return work, p.observe("next", err)
+}
+
+// Wait is not observed because the call returns an error when the
+// pipeline is cancelled, which is not an rpc failure.
+func (p *instrumentedPeer) Wait(c context.Context, id string) error {
+	return p.peer.Wait(c, id)
+}
+
+func (p *instrumentedPeer) Init(c context.Context, id string, state rpc.State) error {
+	return p.observe("init", p.peer.Init(c, id, state))
+}
+
+func (p *instrumentedPeer) Done(c context.Context, id string, state rpc.State) error {
+	return p.observe("done", p.peer.Done(c, id, state))
+}
+
+func (p *instrumentedPeer) Extend(c context.Context, id string) error {
+	return p.observe("extend", p.peer.Extend(c, id))
+}
+
+func (p *instrumentedPeer) Update(c context.Context, id string, state rpc.State) error {
+	return p.observe("update", p.peer.Update(c, id, state))
+}
+
+func (p *instrumentedPeer) Upload(c context.Context, id string, file *rpc.File) error {
+	err := p.peer.Upload(c, id, file)
+	if err == nil {
+		kind := "artifacts"
+		if file.Mime == "application/json+logs" {
+			kind = "logs"
+		}
+		uploadBytes.Add(float64(file.Size), kind)
+	}
+	return p.observe("upload", err)
+}
+
+func (p *instrumentedPeer) Log(c context.Context, id string, line *rpc.Line) error {
+	return p.observe("log", p.peer.Log(c, id, line))
+}
+
+func (p *instrumentedPeer) observe(method string, err error) error {
+	// calls interrupted by the context are not rpc failures.
+	if err != nil && err != context.Canceled && err != context.DeadlineExceeded {
+		rpcErrors.Inc(method, p.transport)
+	}
+	return err
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/cncd/pipeline/pipeline/interrupt"
 	"github.com/cncd/pipeline/pipeline/rpc"
 
+	dockerclient "github.com/docker/docker/client"
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/urfave/cli"
 )
@@ -48,6 +49,20 @@ var onceCommand = cli.Command{
 			Name:   "json",
 			EnvVar: "PIPED_JSON",
 		},
+		cli.StringFlag{
+			Name:   "agent-id",
+			EnvVar: "PIPED_AGENT_ID",
+		},
+		cli.BoolFlag{
+			Name:   "service-fail",
+			EnvVar: "PIPED_SERVICE_FAIL",
+			Usage:  "fail the pipeline when a service exits unsuccessfully",
+		},
+		cli.StringFlag{
+			Name:   "tracing-endpoint",
+			EnvVar: "PIPED_TRACING_ENDPOINT",
+			Usage:  "opentelemetry collector otlp/http endpoint",
+		},
 	},
 }
 
@@ -76,12 +91,27 @@ func once(c *cli.Context) error {
 	}
 	defer client.Close()
 
+	dockerClient, err := dockerclient.NewEnvClient()
+	if err != nil {
+		return err
+	}
+	defer dockerClient.Close()
+
 	ctx := context.Background()
 	ctx = interrupt.WithContextFunc(ctx, func() {
 		println("ctrl+c received, terminating process")
 	})
 
-	return run(ctx, &onceClient{client, c.String("json")}, rpc.NoFilter)
+	r := &runner{
+		client: &onceClient{client, c.String("json")},
+		filter: rpc.NoFilter,
+		agent:  agentID(c),
+		policy: servicePolicy(c),
+		traces: traceExporter(c),
+		docker: dockerClient,
+		abort:  ctx,
+	}
+	return r.run(ctx)
 }
 
 type onceClient struct {
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/backend/docker"
+
+	"github.com/urfave/cli"
+)
+
+var pruneCommand = cli.Command{
+	Name:   "prune",
+	Usage:  "remove resources left behind by crashed pipelines",
+	Action: prune,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:   "agent-id",
+			EnvVar: "PIPED_AGENT_ID",
+			Usage:  "remove all resources created by the agent, defaults to hostname",
+		},
+		cli.DurationFlag{
+			Name:   "max-age",
+			EnvVar: "PIPED_PRUNE_MAX_AGE",
+			Usage:  "remove resources older than the maximum age, created by any agent",
+		},
+	},
+}
+
+func prune(c *cli.Context) error {
+	// the agent is not expected to be running when the prune command
+	// is invoked, so all of its pipelines are considered stopped.
+	report, err := docker.PruneEnv(context.Background(), docker.PruneOptions{
+		Agent:  agentID(c),
+		MaxAge: c.Duration("max-age"),
+	})
+	if report != nil {
+		logPrune(report)
+	}
+	return err
+}
+
+// sweep periodically removes resources created by the agent that
+// belong to pipelines that are no longer running, or that exceed the
+// maximum age.
+func sweep(ctx context.Context, agent string, interval, maxAge time.Duration) {
+	opts := docker.PruneOptions{
+		Agent:   agent,
+		MaxAge:  maxAge,
+		Running: running.has,
+	}
+	for {
+		report, err := docker.PruneEnv(ctx, opts)
+		if err != nil {
+			log.Printf("pipeline: error pruning resources: %s", err)
+		}
+		if report != nil {
+			logPrune(report)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func logPrune(report *docker.PruneReport) {
+	for _, name := range report.Containers {
+		log.Printf("pipeline: pruned container: %s", name)
+	}
+	for _, name := range report.Networks {
+		log.Printf("pipeline: pruned network: %s", name)
+	}
+	for _, name := range report.Volumes {
+		log.Printf("pipeline: pruned volume: %s", name)
+	}
+}
+
+// agentID returns the agent identifier used to label pipeline
+// resources, defaulting to the hostname.
+func agentID(c *cli.Context) string {
+	if id := c.String("agent-id"); id != "" {
+		return id
+	}
+	id, _ := os.Hostname()
+	return id
+}
+
+// running tracks the pipelines currently executed by the agent.
+var running = &pipelineSet{ids: map[string]struct{}{}}
+
+type pipelineSet struct {
+	sync.Mutex
+	ids map[string]struct{}
+}
+
+func (s *pipelineSet) add(id string) {
+	s.Lock()
+	s.ids[id] = struct{}{}
+	s.Unlock()
+}
+
+func (s *pipelineSet) remove(id string) {
+	s.Lock()
+	delete(s.ids, id)
+	s.Unlock()
+}
+
+func (s *pipelineSet) has(id string) bool {
+	s.Lock()
+	defer s.Unlock()
+	_, ok := s.ids[id]
+	return ok
+}
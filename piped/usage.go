@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/rpc"
+)
+
+// mimeUsage is the mime type of the step resource usage artifact.
+const mimeUsage = "application/json+usage"
+
+// uploadUsage uploads the step resource usage as a pipeline artifact,
+// so the server can chart it.
+func (r *runner) uploadUsage(id, proc string, usage *backend.Usage) {
+	file := &rpc.File{}
+	file.Mime = mimeUsage
+	file.Proc = proc
+	file.Name = "usage.json"
+	file.Data, _ = json.Marshal(usage)
+	file.Size = len(file.Data)
+	file.Time = time.Now().Unix()
+
+	if err := r.client.Upload(context.Background(), id, file); err != nil {
+		r.logf("pipeline: cannot upload usage: %s: %s: %s", id, proc, err)
+	}
+}
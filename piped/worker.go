@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/tevino/abool"
+)
+
+// work starts n workers that request and execute pipelines until the
+// stop flag is set or the context is cancelled, and then waits for the
+// pipelines in flight to complete. The workers share the rpc connection
+// and the docker client of the runner.
+func (r *runner) work(ctx context.Context, stop *abool.AtomicBool, n int) error {
+	if n < 1 {
+		n = 1
+	}
+
+	// the workers stop requesting pipelines when a worker fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	for i := 1; i <= n; i++ {
+		w := *r
+		if n > 1 {
+			w.logger = log.New(os.Stderr, fmt.Sprintf("[worker %d] ", i), log.LstdFlags)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.IsSet() {
+				err := w.run(ctx)
+				if err == nil {
+					continue
+				}
+				if ctx.Err() == nil {
+					once.Do(func() {
+						first = err
+						stop.Set()
+						cancel()
+					})
+				}
+				return
+			}
+		}()
+	}
+	wg.Wait()
+
+	if first != nil {
+		return first
+	}
+	if stop.IsSet() {
+		// the workers were stopped by a signal.
+		return nil
+	}
+	return ctx.Err()
+}
+
+// logf writes the message to the runner logger, which is prefixed with
+// the worker number when more than one worker is running.
+func (r *runner) logf(format string, v ...interface{}) {
+	if r.logger != nil {
+		r.logger.Printf(format, v...)
+	} else {
+		log.Printf(format, v...)
+	}
+}
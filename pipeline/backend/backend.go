@@ -12,7 +12,7 @@ type Engine interface {
 	Setup(context.Context, *Config) error
 	// Start the pipeline step.
 	Exec(context.Context, *Step) error
-	// Kill the pipeline step.
+	// Kill sends the stop signal to the pipeline step.
 	Kill(context.Context, *Step) error
 	// Wait for the pipeline step to complete and returns
 	// the completion results.
@@ -22,3 +22,18 @@ type Engine interface {
 	// Destroy the pipeline environment.
 	Destroy(context.Context, *Config) error
 }
+
+// Sampler is an optional engine capability used to sample the resource
+// usage of a pipeline step while it runs.
+type Sampler interface {
+	// Sample the pipeline step resource usage until the step exits or
+	// the context is cancelled, and returns the usage.
+	Sample(context.Context, *Step) (*Usage, error)
+}
+
+// ForceKiller is an optional engine capability used to kill a pipeline
+// step that does not exit after receiving its stop signal.
+type ForceKiller interface {
+	// ForceKill kills the pipeline step immediately.
+	ForceKill(context.Context, *Step) error
+}
@@ -17,6 +17,7 @@ func toConfig(proc *backend.Step) *container.Config {
 		Image:        proc.Image,
 		Labels:       proc.Labels,
 		WorkingDir:   proc.WorkingDir,
+		StopSignal:   proc.StopSignal,
 		AttachStdout: true,
 		AttachStderr: true,
 	}
@@ -123,6 +124,18 @@ func toEnv(env map[string]string) []string {
 	return envs
 }
 
+// helper function that merges the label maps into a new map. Labels
+// in later maps take precedence.
+func mergeLabels(maps ...map[string]string) map[string]string {
+	labels := map[string]string{}
+	for _, m := range maps {
+		for k, v := range m {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
 // helper function that converts a slice of device paths to a slice of
 // container.DeviceMapping.
 func toDev(paths []string) []container.DeviceMapping {
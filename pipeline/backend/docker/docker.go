@@ -4,6 +4,8 @@ import (
 	"context"
 	"io"
 	"io/ioutil"
+	"strconv"
+	"time"
 
 	"github.com/cncd/pipeline/pipeline/backend"
 
@@ -15,24 +17,31 @@ import (
 )
 
 type engine struct {
-	client client.APIClient
+	client   client.APIClient
+	pipeline string
+	agent    string
+	observers []Observer
 }
 
 // New returns a new Docker Engine using the given client.
-func New(cli client.APIClient) backend.Engine {
-	return &engine{
+func New(cli client.APIClient, opts ...Option) backend.Engine {
+	e := &engine{
 		client: cli,
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // NewEnv returns a new Docker Engine using the client connection
 // environment variables.
-func NewEnv() (backend.Engine, error) {
+func NewEnv(opts ...Option) (backend.Engine, error) {
 	cli, err := client.NewEnvClient()
 	if err != nil {
 		return nil, err
 	}
-	return New(cli), nil
+	return New(cli, opts...), nil
 }
 
 func (e *engine) Setup(_ context.Context, conf *backend.Config) error {
@@ -41,7 +50,7 @@ func (e *engine) Setup(_ context.Context, conf *backend.Config) error {
 			Name:       vol.Name,
 			Driver:     vol.Driver,
 			DriverOpts: vol.DriverOpts,
-			// Labels:     defaultLabels,
+			Labels:     e.defaultLabels(),
 		})
 		if err != nil {
 			return err
@@ -51,7 +60,7 @@ func (e *engine) Setup(_ context.Context, conf *backend.Config) error {
 		_, err := e.client.NetworkCreate(noContext, network.Name, types.NetworkCreate{
 			Driver:  network.Driver,
 			Options: network.DriverOpts,
-			// Labels:  defaultLabels,
+			Labels:  e.defaultLabels(),
 		})
 		if err != nil {
 			return err
@@ -62,6 +71,7 @@ func (e *engine) Setup(_ context.Context, conf *backend.Config) error {
 
 func (e *engine) Exec(ctx context.Context, proc *backend.Step) error {
 	config := toConfig(proc)
+	config.Labels = mergeLabels(proc.Labels, e.defaultLabels())
 	hostConfig := toHostConfig(proc)
 
 	// create pull options with encoded authorization credentials.
@@ -73,30 +83,25 @@ func (e *engine) Exec(ctx context.Context, proc *backend.Step) error {
 	// automatically pull the latest version of the image if requested
 	// by the process configuration.
 	if proc.Pull {
-		rc, perr := e.client.ImagePull(ctx, config.Image, pullopts)
-		if perr == nil {
-			io.Copy(ioutil.Discard, rc)
-			rc.Close()
-		}
+		perr := e.pull(ctx, proc, pullopts)
 		// fix for drone/drone#1917
 		if perr != nil && proc.AuthConfig.Password != "" {
 			return perr
 		}
 	}
 
+	created := time.Now()
 	_, err := e.client.ContainerCreate(ctx, config, hostConfig, nil, proc.Name)
 	if client.IsErrImageNotFound(err) {
 		// automatically pull and try to re-create the image if the
 		// failure is caused because the image does not exist.
-		rc, perr := e.client.ImagePull(ctx, config.Image, pullopts)
-		if perr != nil {
+		if perr := e.pull(ctx, proc, pullopts); perr != nil {
 			return perr
 		}
-		io.Copy(ioutil.Discard, rc)
-		rc.Close()
-
+		created = time.Now()
 		_, err = e.client.ContainerCreate(ctx, config, hostConfig, nil, proc.Name)
 	}
+	e.observe(proc, PhaseCreate, created, err)
 	if err != nil {
 		return err
 	}
@@ -125,21 +130,30 @@ func (e *engine) Exec(ctx context.Context, proc *backend.Step) error {
 }
 
 func (e *engine) Kill(_ context.Context, proc *backend.Step) error {
-	return e.client.ContainerKill(noContext, proc.Name, "9")
+	signal := proc.StopSignal
+	if signal == "" {
+		signal = defaultStopSignal
+	}
+	return e.client.ContainerKill(noContext, proc.Name, signal)
+}
+
+func (e *engine) ForceKill(_ context.Context, proc *backend.Step) error {
+	return e.client.ContainerKill(noContext, proc.Name, "SIGKILL")
 }
 
 func (e *engine) Wait(ctx context.Context, proc *backend.Step) (*backend.State, error) {
-	_, err := e.client.ContainerWait(ctx, proc.Name)
-	if err != nil {
-		// todo
-	}
+	started := time.Now()
+	_, werr := e.client.ContainerWait(ctx, proc.Name)
+	e.observe(proc, PhaseWait, started, werr)
 
 	info, err := e.client.ContainerInspect(noContext, proc.Name)
 	if err != nil {
 		return nil, err
 	}
-	if info.State.Running {
-		// todo
+	if info.State.Running && werr != nil {
+		// the wait was interrupted, most likely by the context
+		// being cancelled, before the container exited.
+		return nil, werr
 	}
 
 	return &backend.State{
@@ -165,22 +179,71 @@ func (e *engine) Tail(ctx context.Context, proc *backend.Step) (io.ReadCloser, e
 	return rc, nil
 }
 
+// helper function pulls the step image.
+func (e *engine) pull(ctx context.Context, proc *backend.Step, opts types.ImagePullOptions) error {
+	started := time.Now()
+	rc, err := e.client.ImagePull(ctx, proc.Image, opts)
+	if err == nil {
+		io.Copy(ioutil.Discard, rc)
+		rc.Close()
+	}
+	e.observe(proc, PhasePull, started, err)
+	return err
+}
+
+// helper function notifies the observers that a phase of the step is
+// complete.
+func (e *engine) observe(proc *backend.Step, phase string, started time.Time, err error) {
+	for _, observer := range e.observers {
+		observer(proc, phase, started, err)
+	}
+}
+
 func (e *engine) Destroy(_ context.Context, conf *backend.Config) error {
+	var errs []error
 	for _, stage := range conf.Stages {
 		for _, step := range stage.Steps {
+			// the kill error is ignored because the container is
+			// expected to have exited already.
 			e.client.ContainerKill(noContext, step.Name, "9")
-			e.client.ContainerRemove(noContext, step.Name, removeOpts)
+			errs = append(errs, e.client.ContainerRemove(noContext, step.Name, removeOpts))
 		}
 	}
 	for _, volume := range conf.Volumes {
-		e.client.VolumeRemove(noContext, volume.Name, true)
+		errs = append(errs, e.client.VolumeRemove(noContext, volume.Name, true))
 	}
 	for _, network := range conf.Networks {
-		e.client.NetworkRemove(noContext, network.Name)
+		errs = append(errs, e.client.NetworkRemove(noContext, network.Name))
+	}
+	// resources that were never created, for example containers
+	// for skipped steps, are not considered an error.
+	for _, err := range errs {
+		if err != nil && !client.IsErrNotFound(err) {
+			return err
+		}
 	}
 	return nil
 }
 
+// helper function returns the labels added to every resource created
+// by the engine.
+func (e *engine) defaultLabels() map[string]string {
+	labels := map[string]string{
+		LabelCreated: strconv.FormatInt(time.Now().Unix(), 10),
+	}
+	if e.pipeline != "" {
+		labels[LabelPipeline] = e.pipeline
+	}
+	if e.agent != "" {
+		labels[LabelAgent] = e.agent
+	}
+	return labels
+}
+
+// defaultStopSignal is sent to the container when the step does not
+// define a stop signal.
+const defaultStopSignal = "SIGTERM"
+
 var (
 	noContext = context.Background()
 
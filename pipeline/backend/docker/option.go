@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+// Labels added to every container, volume and network created by the
+// engine. They are used to identify resources left behind by pipelines
+// that did not finish cleanly.
+const (
+	LabelPipeline = "io.cncd.pipeline.id"
+	LabelAgent    = "io.cncd.pipeline.agent"
+	LabelCreated  = "io.cncd.pipeline.created"
+)
+
+// Phases of a step reported to the engine observer.
+const (
+	PhasePull   = "pull"
+	PhaseCreate = "create"
+	PhaseWait   = "wait"
+)
+
+// Observer is notified when the engine completes a phase of a step,
+// with the time the phase started and the phase error, if any.
+type Observer func(proc *backend.Step, phase string, started time.Time, err error)
+
+// Option configures a Docker engine option.
+type Option func(*engine)
+
+// WithPipeline configures the engine with the identifier of the
+// pipeline it executes. The identifier is added as a label to every
+// resource the engine creates.
+func WithPipeline(id string) Option {
+	return func(e *engine) {
+		e.pipeline = id
+	}
+}
+
+// WithAgent configures the engine with the identifier of the agent
+// that executes the pipeline. The identifier is added as a label to
+// every resource the engine creates.
+func WithAgent(id string) Option {
+	return func(e *engine) {
+		e.agent = id
+	}
+}
+
+// WithObserver configures the engine with an observer that is notified
+// when the engine pulls an image, creates a container or waits for a
+// container to exit. The option can be used more than once.
+func WithObserver(observer Observer) Option {
+	return func(e *engine) {
+		e.observers = append(e.observers, observer)
+	}
+}
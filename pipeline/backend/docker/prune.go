@@ -0,0 +1,151 @@
+package docker
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+type (
+	// PruneOptions defines the criteria used to select orphaned
+	// containers, volumes and networks.
+	PruneOptions struct {
+		// Agent selects resources created by the named agent. These
+		// are removed when their pipeline is no longer running.
+		Agent string
+
+		// Running reports whether the pipeline with the given
+		// identifier is still running. Resources of running pipelines
+		// are never removed. If nil, no pipeline is considered running.
+		Running func(id string) bool
+
+		// MaxAge selects resources created by any agent that are
+		// older than the maximum age. A zero value disables the check.
+		//
+		// This is a hard kill: Running only knows about the pipelines
+		// of this agent, so the resources of pipelines still running
+		// on other agents sharing the daemon are removed as well.
+		MaxAge time.Duration
+	}
+
+	// PruneReport lists the resources removed by Prune.
+	PruneReport struct {
+		Containers []string
+		Volumes    []string
+		Networks   []string
+	}
+)
+
+// Prune removes the containers, volumes and networks created by the
+// engine that match the prune options. Removal continues when a single
+// resource cannot be removed, and the first error is returned.
+func Prune(ctx context.Context, cli client.APIClient, opts PruneOptions) (*PruneReport, error) {
+	var errs []error
+	report := new(PruneReport)
+	now := time.Now()
+
+	args := filters.NewArgs()
+	args.Add("label", LabelCreated)
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return report, err
+	}
+	for _, container := range containers {
+		if !opts.match(container.Labels, now) {
+			continue
+		}
+		err := cli.ContainerRemove(ctx, container.ID, pruneOpts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		report.Containers = append(report.Containers, containerName(container))
+	}
+
+	// networks and volumes are removed after the containers, since
+	// they cannot be removed while still in use.
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{Filters: args})
+	if err != nil {
+		return report, err
+	}
+	for _, network := range networks {
+		if !opts.match(network.Labels, now) {
+			continue
+		}
+		err := cli.NetworkRemove(ctx, network.ID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		report.Networks = append(report.Networks, network.Name)
+	}
+
+	volumes, err := cli.VolumeList(ctx, args)
+	if err != nil {
+		return report, err
+	}
+	for _, volume := range volumes.Volumes {
+		if !opts.match(volume.Labels, now) {
+			continue
+		}
+		err := cli.VolumeRemove(ctx, volume.Name, true)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		report.Volumes = append(report.Volumes, volume.Name)
+	}
+
+	if len(errs) != 0 {
+		return report, errs[0]
+	}
+	return report, nil
+}
+
+// PruneEnv removes orphaned resources using the client connection
+// environment variables.
+func PruneEnv(ctx context.Context, opts PruneOptions) (*PruneReport, error) {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return nil, err
+	}
+	return Prune(ctx, cli, opts)
+}
+
+// match returns true if the resource with the given labels should be
+// removed.
+func (o *PruneOptions) match(labels map[string]string, now time.Time) bool {
+	if o.Running != nil && o.Running(labels[LabelPipeline]) {
+		return false
+	}
+	if o.MaxAge != 0 {
+		created, err := strconv.ParseInt(labels[LabelCreated], 10, 64)
+		if err == nil && now.Sub(time.Unix(created, 0)) > o.MaxAge {
+			return true
+		}
+	}
+	return o.Agent != "" && labels[LabelAgent] == o.Agent
+}
+
+// helper function returns the container name, without the leading
+// slash, or the container id if the container is unnamed.
+func containerName(container types.Container) string {
+	if len(container.Names) == 0 {
+		return container.ID
+	}
+	name := container.Names[0]
+	if len(name) != 0 && name[0] == '/' {
+		name = name[1:]
+	}
+	return name
+}
+
+var pruneOpts = types.ContainerRemoveOptions{
+	RemoveVolumes: true,
+	Force:         true,
+}
@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPruneMatch(t *testing.T) {
+	now := time.Now()
+	created := func(d time.Duration) string {
+		return strconv.FormatInt(now.Add(-d).Unix(), 10)
+	}
+	running := func(id string) bool {
+		return id == "1"
+	}
+
+	testdata := []struct {
+		opts   PruneOptions
+		labels map[string]string
+		want   bool
+	}{
+		// resources owned by the agent are removed when the pipeline
+		// is no longer running.
+		{
+			opts:   PruneOptions{Agent: "agent1", Running: running},
+			labels: map[string]string{LabelAgent: "agent1", LabelPipeline: "2", LabelCreated: created(time.Minute)},
+			want:   true,
+		},
+		{
+			opts:   PruneOptions{Agent: "agent1", Running: running},
+			labels: map[string]string{LabelAgent: "agent1", LabelPipeline: "1", LabelCreated: created(time.Minute)},
+			want:   false,
+		},
+		{
+			opts:   PruneOptions{Agent: "agent1"},
+			labels: map[string]string{LabelAgent: "agent1", LabelPipeline: "1", LabelCreated: created(time.Minute)},
+			want:   true,
+		},
+		// resources owned by other agents are ignored.
+		{
+			opts:   PruneOptions{Agent: "agent1", Running: running},
+			labels: map[string]string{LabelAgent: "agent2", LabelPipeline: "2", LabelCreated: created(time.Minute)},
+			want:   false,
+		},
+		{
+			opts:   PruneOptions{Running: running},
+			labels: map[string]string{LabelCreated: created(time.Minute)},
+			want:   false,
+		},
+		// resources older than the maximum age are removed, unless
+		// the pipeline is still running.
+		{
+			opts:   PruneOptions{Agent: "agent1", Running: running, MaxAge: time.Hour},
+			labels: map[string]string{LabelAgent: "agent2", LabelPipeline: "2", LabelCreated: created(2 * time.Hour)},
+			want:   true,
+		},
+		{
+			opts:   PruneOptions{Agent: "agent1", Running: running, MaxAge: time.Hour},
+			labels: map[string]string{LabelAgent: "agent1", LabelPipeline: "1", LabelCreated: created(2 * time.Hour)},
+			want:   false,
+		},
+		{
+			opts:   PruneOptions{MaxAge: time.Hour},
+			labels: map[string]string{LabelCreated: created(time.Minute)},
+			want:   false,
+		},
+		{
+			opts:   PruneOptions{MaxAge: time.Hour},
+			labels: map[string]string{LabelCreated: "invalid"},
+			want:   false,
+		},
+	}
+
+	for i, test := range testdata {
+		if got := test.opts.match(test.labels, now); got != test.want {
+			t.Errorf("Want match %v at index %d, got %v", test.want, i, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Sample streams the container stats until the container exits or the
+// context is cancelled, and returns the accumulated resource usage.
+func (e *engine) Sample(ctx context.Context, proc *backend.Step) (*backend.Usage, error) {
+	stats, err := e.client.ContainerStats(ctx, proc.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	defer stats.Body.Close()
+
+	usage := new(backend.Usage)
+	dec := json.NewDecoder(stats.Body)
+	for {
+		sample := new(types.StatsJSON)
+		if err := dec.Decode(sample); err != nil {
+			// the stream ends with an error when the container exits
+			// or the context is cancelled, in which case the usage
+			// sampled so far is returned.
+			return usage, nil
+		}
+		accumulate(usage, sample)
+	}
+}
+
+// helper function accumulates the container stats sample into the
+// resource usage. Memory is tracked as a peak, all other counters are
+// cumulative and replaced by the latest sample.
+func accumulate(usage *backend.Usage, sample *types.StatsJSON) {
+	mem := sample.MemoryStats.MaxUsage
+	if sample.MemoryStats.Usage > mem {
+		mem = sample.MemoryStats.Usage
+	}
+	if mem > usage.MemoryPeak {
+		usage.MemoryPeak = mem
+	}
+
+	if total := sample.CPUStats.CPUUsage.TotalUsage; total != 0 {
+		usage.CPUSeconds = float64(total) / 1e9
+	}
+
+	var read, write uint64
+	for _, entry := range sample.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += entry.Value
+		case "write":
+			write += entry.Value
+		}
+	}
+	if read != 0 || write != 0 {
+		usage.BlockRead, usage.BlockWrite = read, write
+	}
+
+	var rx, tx uint64
+	for _, network := range sample.Networks {
+		rx += network.RxBytes
+		tx += network.TxBytes
+	}
+	if rx != 0 || tx != 0 {
+		usage.NetRx, usage.NetTx = rx, tx
+	}
+}
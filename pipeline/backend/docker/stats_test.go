@@ -0,0 +1,44 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestAccumulate(t *testing.T) {
+	first := new(types.StatsJSON)
+	first.MemoryStats.Usage = 300
+	first.CPUStats.CPUUsage.TotalUsage = 500000000
+	first.BlkioStats.IoServiceBytesRecursive = []types.BlkioStatEntry{
+		{Op: "Read", Value: 10},
+		{Op: "Write", Value: 20},
+		{Op: "Total", Value: 30},
+	}
+	first.Networks = map[string]types.NetworkStats{
+		"eth0": {RxBytes: 1, TxBytes: 2},
+		"eth1": {RxBytes: 3, TxBytes: 4},
+	}
+
+	// the stats of an exited container are empty.
+	second := new(types.StatsJSON)
+	second.MemoryStats.Usage = 100
+
+	usage := new(backend.Usage)
+	accumulate(usage, first)
+	accumulate(usage, second)
+
+	want := backend.Usage{
+		MemoryPeak: 300,
+		CPUSeconds: 0.5,
+		BlockRead:  10,
+		BlockWrite: 20,
+		NetRx:      4,
+		NetTx:      6,
+	}
+	if *usage != want {
+		t.Errorf("Want usage %+v, got %+v", want, *usage)
+	}
+}
@@ -0,0 +1,201 @@
+package export
+
+import (
+	"io"
+	"strings"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	composeFile struct {
+		Services map[string]*composeService `yaml:"services"`
+		Networks map[string]*composeNetwork `yaml:"networks,omitempty"`
+		Volumes  map[string]*composeVolume  `yaml:"volumes,omitempty"`
+	}
+
+	composeService struct {
+		Image           string                        `yaml:"image,omitempty"`
+		ContainerName   string                        `yaml:"container_name,omitempty"`
+		Profiles        []string                      `yaml:"profiles,omitempty"`
+		DependsOn       map[string]composeDependency  `yaml:"depends_on,omitempty"`
+		Privileged      bool                          `yaml:"privileged,omitempty"`
+		WorkingDir      string                        `yaml:"working_dir,omitempty"`
+		Environment     map[string]string             `yaml:"environment,omitempty"`
+		Labels          map[string]string             `yaml:"labels,omitempty"`
+		Entrypoint      []string                      `yaml:"entrypoint,omitempty"`
+		Command         []string                      `yaml:"command,omitempty"`
+		ExtraHosts      []string                      `yaml:"extra_hosts,omitempty"`
+		Volumes         []string                      `yaml:"volumes,omitempty"`
+		Tmpfs           []string                      `yaml:"tmpfs,omitempty"`
+		Devices         []string                      `yaml:"devices,omitempty"`
+		Networks        map[string]*composeConnection `yaml:"networks,omitempty"`
+		NetworkMode     string                        `yaml:"network_mode,omitempty"`
+		DNS             []string                      `yaml:"dns,omitempty"`
+		DNSSearch       []string                      `yaml:"dns_search,omitempty"`
+		MemSwapLimit    int64                         `yaml:"memswap_limit,omitempty"`
+		MemLimit        int64                         `yaml:"mem_limit,omitempty"`
+		ShmSize         int64                         `yaml:"shm_size,omitempty"`
+		CPUQuota        int64                         `yaml:"cpu_quota,omitempty"`
+		CPUShares       int64                         `yaml:"cpu_shares,omitempty"`
+		CPUSet          string                        `yaml:"cpuset,omitempty"`
+		Ipc             string                        `yaml:"ipc,omitempty"`
+		Sysctls         map[string]string             `yaml:"sysctls,omitempty"`
+		StopSignal      string                        `yaml:"stop_signal,omitempty"`
+		StopGracePeriod string                        `yaml:"stop_grace_period,omitempty"`
+	}
+
+	composeDependency struct {
+		Condition string `yaml:"condition"`
+	}
+
+	composeConnection struct {
+		Aliases []string `yaml:"aliases,omitempty"`
+	}
+
+	composeNetwork struct {
+		Driver     string            `yaml:"driver,omitempty"`
+		DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
+	}
+
+	composeVolume struct {
+		Driver     string            `yaml:"driver,omitempty"`
+		DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
+	}
+)
+
+// profileOnFailure is the compose profile of the steps that only run
+// when the pipeline fails. These steps are not started by default.
+const profileOnFailure = "on_failure"
+
+// Compose writes the configuration as a docker-compose file. Each step
+// is a service that depends on the steps of the previous stage: steps
+// wait for the previous steps to complete successfully, and for the
+// previous services to start. Steps that only run when the pipeline
+// fails are assigned to the on_failure profile, without dependencies,
+// so that they can be started by hand.
+func Compose(w io.Writer, config *backend.Config) error {
+	file := &composeFile{
+		Services: map[string]*composeService{},
+		Networks: map[string]*composeNetwork{},
+		Volumes:  map[string]*composeVolume{},
+	}
+	for _, network := range config.Networks {
+		file.Networks[network.Name] = &composeNetwork{
+			Driver:     network.Driver,
+			DriverOpts: network.DriverOpts,
+		}
+	}
+	for _, volume := range config.Volumes {
+		file.Volumes[volume.Name] = &composeVolume{
+			Driver:     volume.Driver,
+			DriverOpts: volume.DriverOpts,
+		}
+	}
+
+	var previous []*backend.Step
+	for _, stage := range config.Stages {
+		var current []*backend.Step
+		for _, proc := range stage.Steps {
+			service := toComposeService(proc)
+			file.Services[stepName(proc)] = service
+			if onFailureOnly(proc) {
+				continue
+			}
+			current = append(current, proc)
+			for _, dep := range previous {
+				condition := "service_completed_successfully"
+				if dep.Detached {
+					condition = "service_started"
+				}
+				if service.DependsOn == nil {
+					service.DependsOn = map[string]composeDependency{}
+				}
+				service.DependsOn[stepName(dep)] = composeDependency{condition}
+			}
+		}
+		if len(current) != 0 {
+			previous = current
+		}
+	}
+
+	out, err := yaml.Marshal(file)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// toComposeService returns the compose service for the step.
+func toComposeService(proc *backend.Step) *composeService {
+	service := &composeService{
+		Image:         escape(proc.Image),
+		ContainerName: proc.Name,
+		Privileged:    proc.Privileged,
+		WorkingDir:    escape(proc.WorkingDir),
+		Environment:   escapeMap(proc.Environment),
+		Labels:        escapeMap(proc.Labels),
+		Entrypoint:    escapeSlice(entrypoint(proc)),
+		Command:       escapeSlice(proc.Command),
+		ExtraHosts:    proc.ExtraHosts,
+		Volumes:       escapeSlice(proc.Volumes),
+		Tmpfs:         proc.Tmpfs,
+		Devices:       proc.Devices,
+		NetworkMode:   proc.NetworkMode,
+		DNS:           proc.DNS,
+		DNSSearch:     proc.DNSSearch,
+		MemSwapLimit:  proc.MemSwapLimit,
+		MemLimit:      proc.MemLimit,
+		ShmSize:       proc.ShmSize,
+		CPUQuota:      proc.CPUQuota,
+		CPUShares:     proc.CPUShares,
+		CPUSet:        proc.CPUSet,
+		Ipc:           proc.IpcMode,
+		Sysctls:       proc.Sysctls,
+		StopSignal:    proc.StopSignal,
+	}
+	if proc.StopGrace != 0 {
+		service.StopGracePeriod = proc.StopGrace.String()
+	}
+	if onFailureOnly(proc) {
+		service.Profiles = []string{profileOnFailure}
+	}
+	// the network mode and the networks cannot be combined.
+	if proc.NetworkMode == "" && len(proc.Networks) != 0 {
+		service.Networks = map[string]*composeConnection{}
+		for _, conn := range proc.Networks {
+			service.Networks[conn.Name] = &composeConnection{
+				Aliases: conn.Aliases,
+			}
+		}
+	}
+	return service
+}
+
+// escape escapes the dollar signs, so that the value is not
+// interpolated by docker-compose.
+func escape(s string) string {
+	return strings.Replace(s, "$", "$$", -1)
+}
+
+func escapeSlice(list []string) []string {
+	var escaped []string
+	for _, s := range list {
+		escaped = append(escaped, escape(s))
+	}
+	return escaped
+}
+
+func escapeMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	escaped := map[string]string{}
+	for k, v := range m {
+		escaped[k] = escape(v)
+	}
+	return escaped
+}
@@ -0,0 +1,91 @@
+// Package export converts a compiled pipeline configuration to formats
+// that can be executed without the pipeline runtime: a docker-compose
+// file, a set of Kubernetes manifests, and a POSIX shell script.
+//
+// The exported pipelines approximate the runtime behavior. Registry
+// credentials are not exported, and secrets are written in plain text
+// as part of the step environment. In the docker-compose file and the
+// Kubernetes manifests, steps that are allowed to fail run in a shell
+// that ignores their exit code, so the step image must provide a shell.
+// Steps that are allowed to fail but use the image entrypoint cannot be
+// wrapped, and their failure stops the pipeline. Steps that run on both
+// success and failure only run when the previous steps succeed.
+package export
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+// steps returns the pipeline steps in execution order.
+func steps(config *backend.Config) []*backend.Step {
+	var list []*backend.Step
+	for _, stage := range config.Stages {
+		list = append(list, stage.Steps...)
+	}
+	return list
+}
+
+// stepName returns the step alias, or the step name if the step has no
+// alias.
+func stepName(proc *backend.Step) string {
+	if proc.Alias != "" {
+		return proc.Alias
+	}
+	return proc.Name
+}
+
+// onFailureOnly returns true if the step only runs when the pipeline
+// fails.
+func onFailureOnly(proc *backend.Step) bool {
+	return proc.OnFailure && !proc.OnSuccess
+}
+
+// entrypoint returns the step entrypoint. If the step is allowed to
+// fail, the entrypoint is wrapped in a shell that ignores the exit code.
+// Steps that use the image entrypoint are not wrapped, since the image
+// entrypoint is not known.
+func entrypoint(proc *backend.Step) []string {
+	if !proc.AllowFailure || len(proc.Entrypoint) == 0 {
+		return proc.Entrypoint
+	}
+	return append([]string{"/bin/sh", "-c", `"$0" "$@" || true`}, proc.Entrypoint...)
+}
+
+// volumeParts splits a volume string into the source, the target and
+// the mode. If the volume has no source, the source is empty.
+func volumeParts(volume string) (src, dst, mode string) {
+	parts := strings.SplitN(volume, ":", 3)
+	switch len(parts) {
+	case 1:
+		return "", parts[0], ""
+	case 2:
+		return parts[0], parts[1], ""
+	default:
+		return parts[0], parts[1], parts[2]
+	}
+}
+
+// sortedKeys returns the map keys in sorted order.
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var reInvalidName = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// dnsName converts the name to a valid dns label.
+func dnsName(name string) string {
+	name = reInvalidName.ReplaceAllString(strings.ToLower(name), "-")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.Trim(name, "-")
+}
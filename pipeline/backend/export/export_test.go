@@ -0,0 +1,190 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestCompose(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Compose(&buf, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+	file := new(composeFile)
+	if err := yaml.Unmarshal(buf.Bytes(), file); err != nil {
+		t.Fatal(err)
+	}
+
+	build := file.Services["build"]
+	if build == nil {
+		t.Fatalf("Want service for the build step")
+	}
+	want := map[string]composeDependency{"database": {"service_started"}}
+	if !reflect.DeepEqual(build.DependsOn, want) {
+		t.Errorf("Want build depends on %v, got %v", want, build.DependsOn)
+	}
+	want = map[string]composeDependency{"clone": {"service_completed_successfully"}}
+	if got := file.Services["database"].DependsOn; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want database depends on %v, got %v", want, got)
+	}
+	if got, want := build.Command[0], "echo $$CI_SCRIPT | base64 -d | /bin/sh -e"; got != want {
+		t.Errorf("Want dollar signs escaped %q, got %q", want, got)
+	}
+	if got := file.Services["notify"]; got == nil || len(got.DependsOn) != 0 || !reflect.DeepEqual(got.Profiles, []string{profileOnFailure}) {
+		t.Errorf("Want on_failure step assigned to the %s profile, got %+v", profileOnFailure, got)
+	}
+	if _, ok := file.Volumes["pipeline_default"]; !ok {
+		t.Errorf("Want pipeline volume exported")
+	}
+}
+
+func TestKubernetes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Kubernetes(&buf, testConfig(), "pipeline_1"); err != nil {
+		t.Fatal(err)
+	}
+	docs := strings.Split(buf.String(), "---\n")
+	if len(docs) != 3 {
+		t.Fatalf("Want a pod and service for the database, and a job, got %d documents", len(docs))
+	}
+
+	job := new(struct {
+		Kind     string
+		Metadata k8sMeta
+		Spec     k8sJobSpec
+	})
+	if err := yaml.Unmarshal([]byte(docs[2]), job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Kind != "Job" || job.Metadata.Name != "pipeline-1" {
+		t.Errorf("Want job pipeline-1, got %s %s", job.Kind, job.Metadata.Name)
+	}
+	pod := job.Spec.Template.Spec
+	if len(pod.InitContainers) != 1 || pod.InitContainers[0].Name != "clone" {
+		t.Errorf("Want clone step as init container, got %+v", pod.InitContainers)
+	}
+	if len(pod.Containers) != 1 || pod.Containers[0].Name != "build" {
+		t.Errorf("Want build step as container, got %+v", pod.Containers)
+	}
+	if len(pod.Volumes) != 1 || pod.Volumes[0].Name != "pipeline-default" || pod.Volumes[0].EmptyDir == nil {
+		t.Errorf("Want shared emptyDir volume, got %+v", pod.Volumes)
+	}
+	if !strings.Contains(docs[1], "name: database") || !strings.Contains(docs[1], "clusterIP: None") {
+		t.Errorf("Want headless service for the database, got %s", docs[1])
+	}
+}
+
+func TestShell(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Shell(&buf, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+	script := buf.String()
+	for _, want := range []string{
+		"docker volume create --driver 'local' 'pipeline_default'",
+		"docker create --name 'pipeline_services_0'",
+		"--env 'CI_SCRIPT=ZWNobyBoZWxsbw=='",
+		"--network-alias 'build'",
+		"--entrypoint '/bin/sh' 'golang' '-c' 'echo $CI_SCRIPT | base64 -d | /bin/sh -e'",
+		"docker start --attach 'pipeline_step_0' || status=$?",
+		"if [ \"$status\" -ne 0 ]; then\n\tdocker create --name 'pipeline_step_1'",
+		"exit $status",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Want script to contain %q, got\n%s", want, script)
+		}
+	}
+}
+
+func TestAllowFailure(t *testing.T) {
+	config := testConfig()
+	config.Stages[2].Steps[0].AllowFailure = true
+
+	var buf bytes.Buffer
+	if err := Compose(&buf, config); err != nil {
+		t.Fatal(err)
+	}
+	file := new(composeFile)
+	if err := yaml.Unmarshal(buf.Bytes(), file); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/bin/sh", "-c", `"$$0" "$$@" || true`, "/bin/sh", "-c"}
+	if got := file.Services["build"].Entrypoint; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want compose entrypoint ignoring the exit code %q, got %q", want, got)
+	}
+
+	buf.Reset()
+	if err := Kubernetes(&buf, config, "pipeline_1"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "- '\"$0\" \"$@\" || true'"; !strings.Contains(buf.String(), want) {
+		t.Errorf("Want kubernetes command ignoring the exit code %q, got\n%s", want, buf.String())
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got, want := quote("it's"), `'it'\''s'`; got != want {
+		t.Errorf("Want quoted %s, got %s", want, got)
+	}
+}
+
+func testConfig() *backend.Config {
+	network := []backend.Conn{{Name: "pipeline_default", Aliases: []string{"build"}}}
+	return &backend.Config{
+		Networks: []*backend.Network{{Name: "pipeline_default", Driver: "bridge"}},
+		Volumes:  []*backend.Volume{{Name: "pipeline_default", Driver: "local"}},
+		Stages: []*backend.Stage{
+			{
+				Name: "pipeline_clone",
+				Steps: []*backend.Step{{
+					Name:      "pipeline_clone",
+					Alias:     "clone",
+					Image:     "plugins/git",
+					Volumes:   []string{"pipeline_default:/pipeline"},
+					OnSuccess: true,
+				}},
+			},
+			{
+				Name: "pipeline_services",
+				Steps: []*backend.Step{{
+					Name:      "pipeline_services_0",
+					Alias:     "database",
+					Image:     "postgres",
+					Detached:  true,
+					OnSuccess: true,
+				}},
+			},
+			{
+				Name:  "pipeline_stage_0",
+				Alias: "build",
+				Steps: []*backend.Step{{
+					Name:        "pipeline_step_0",
+					Alias:       "build",
+					Image:       "golang",
+					Entrypoint:  []string{"/bin/sh", "-c"},
+					Command:     []string{"echo $CI_SCRIPT | base64 -d | /bin/sh -e"},
+					Environment: map[string]string{"CI_SCRIPT": "ZWNobyBoZWxsbw=="},
+					Volumes:     []string{"pipeline_default:/pipeline"},
+					Networks:    network,
+					OnSuccess:   true,
+				}},
+			},
+			{
+				Name:  "pipeline_stage_1",
+				Alias: "notify",
+				Steps: []*backend.Step{{
+					Name:      "pipeline_step_1",
+					Alias:     "notify",
+					Image:     "plugins/slack",
+					OnFailure: true,
+				}},
+			},
+		},
+	}
+}
@@ -0,0 +1,261 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ErrNoSteps is returned when the configuration has no step that can be
+// exported.
+var ErrNoSteps = errors.New("export: the pipeline has no steps")
+
+type (
+	k8sObject struct {
+		APIVersion string      `yaml:"apiVersion"`
+		Kind       string      `yaml:"kind"`
+		Metadata   k8sMeta     `yaml:"metadata"`
+		Spec       interface{} `yaml:"spec"`
+	}
+
+	k8sMeta struct {
+		Name   string            `yaml:"name,omitempty"`
+		Labels map[string]string `yaml:"labels,omitempty"`
+	}
+
+	k8sJobSpec struct {
+		BackoffLimit int         `yaml:"backoffLimit"`
+		Template     k8sTemplate `yaml:"template"`
+	}
+
+	k8sTemplate struct {
+		Metadata k8sMeta    `yaml:"metadata"`
+		Spec     k8sPodSpec `yaml:"spec"`
+	}
+
+	k8sServiceSpec struct {
+		ClusterIP string            `yaml:"clusterIP"`
+		Selector  map[string]string `yaml:"selector"`
+	}
+
+	k8sPodSpec struct {
+		RestartPolicy  string         `yaml:"restartPolicy"`
+		InitContainers []k8sContainer `yaml:"initContainers,omitempty"`
+		Containers     []k8sContainer `yaml:"containers"`
+		Volumes        []k8sVolume    `yaml:"volumes,omitempty"`
+	}
+
+	k8sContainer struct {
+		Name            string        `yaml:"name"`
+		Image           string        `yaml:"image"`
+		ImagePullPolicy string        `yaml:"imagePullPolicy,omitempty"`
+		Command         []string      `yaml:"command,omitempty"`
+		Args            []string      `yaml:"args,omitempty"`
+		WorkingDir      string        `yaml:"workingDir,omitempty"`
+		Env             []k8sEnv      `yaml:"env,omitempty"`
+		VolumeMounts    []k8sMount    `yaml:"volumeMounts,omitempty"`
+		Resources       *k8sResources `yaml:"resources,omitempty"`
+		SecurityContext *k8sSecurity  `yaml:"securityContext,omitempty"`
+	}
+
+	k8sEnv struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	}
+
+	k8sMount struct {
+		Name      string `yaml:"name"`
+		MountPath string `yaml:"mountPath"`
+		ReadOnly  bool   `yaml:"readOnly,omitempty"`
+	}
+
+	k8sResources struct {
+		Limits   map[string]string `yaml:"limits,omitempty"`
+		Requests map[string]string `yaml:"requests,omitempty"`
+	}
+
+	k8sSecurity struct {
+		Privileged bool `yaml:"privileged"`
+	}
+
+	k8sVolume struct {
+		Name     string       `yaml:"name"`
+		EmptyDir *k8sEmptyDir `yaml:"emptyDir,omitempty"`
+		HostPath *k8sHostPath `yaml:"hostPath,omitempty"`
+	}
+
+	k8sEmptyDir struct {
+		Medium string `yaml:"medium,omitempty"`
+	}
+
+	k8sHostPath struct {
+		Path string `yaml:"path"`
+	}
+)
+
+// labelStep is the label used to select the pod of a service.
+const labelStep = "pipeline.step"
+
+// Kubernetes writes the configuration as a set of Kubernetes manifests
+// with the given name. The steps run in order as the containers of a
+// single Job pod, and share the pipeline volumes as emptyDir volumes.
+// Each service runs in its own pod, exposed by a headless Service named
+// after the step, so that the steps can reach it by name.
+//
+// Steps that only run when the pipeline fails are not exported. Devices,
+// extra hosts, dns settings and sysctls are not supported.
+func Kubernetes(w io.Writer, config *backend.Config, name string) error {
+	var (
+		services []*backend.Step
+		procs    []*backend.Step
+	)
+	for _, proc := range steps(config) {
+		switch {
+		case proc.Detached:
+			services = append(services, proc)
+		case !onFailureOnly(proc):
+			procs = append(procs, proc)
+		}
+	}
+	if len(procs) == 0 {
+		return ErrNoSteps
+	}
+
+	var objects []*k8sObject
+	for _, proc := range services {
+		container, volumes := toK8sContainer(proc)
+		labels := map[string]string{labelStep: dnsName(proc.Name)}
+		objects = append(objects,
+			&k8sObject{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Metadata:   k8sMeta{Name: dnsName(proc.Name), Labels: labels},
+				Spec: k8sPodSpec{
+					RestartPolicy: "Never",
+					Containers:    []k8sContainer{container},
+					Volumes:       volumes,
+				},
+			},
+			&k8sObject{
+				APIVersion: "v1",
+				Kind:       "Service",
+				Metadata:   k8sMeta{Name: dnsName(stepName(proc))},
+				Spec: k8sServiceSpec{
+					ClusterIP: "None",
+					Selector:  labels,
+				},
+			},
+		)
+	}
+
+	pod := k8sPodSpec{RestartPolicy: "Never"}
+	seen := map[string]bool{}
+	for i, proc := range procs {
+		container, volumes := toK8sContainer(proc)
+		if i == len(procs)-1 {
+			pod.Containers = append(pod.Containers, container)
+		} else {
+			pod.InitContainers = append(pod.InitContainers, container)
+		}
+		for _, volume := range volumes {
+			if !seen[volume.Name] {
+				seen[volume.Name] = true
+				pod.Volumes = append(pod.Volumes, volume)
+			}
+		}
+	}
+	objects = append(objects, &k8sObject{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Metadata:   k8sMeta{Name: dnsName(name)},
+		Spec: k8sJobSpec{
+			Template: k8sTemplate{Spec: pod},
+		},
+	})
+
+	var buf bytes.Buffer
+	for i, object := range objects {
+		if i != 0 {
+			buf.WriteString("---\n")
+		}
+		out, err := yaml.Marshal(object)
+		if err != nil {
+			return err
+		}
+		buf.Write(out)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// toK8sContainer returns the container for the step, and the volumes
+// mounted by the container.
+func toK8sContainer(proc *backend.Step) (k8sContainer, []k8sVolume) {
+	container := k8sContainer{
+		Name:       dnsName(stepName(proc)),
+		Image:      proc.Image,
+		Command:    entrypoint(proc),
+		Args:       proc.Command,
+		WorkingDir: proc.WorkingDir,
+	}
+	if proc.Pull {
+		container.ImagePullPolicy = "Always"
+	}
+	for _, k := range sortedKeys(proc.Environment) {
+		container.Env = append(container.Env, k8sEnv{Name: k, Value: proc.Environment[k]})
+	}
+	if proc.Privileged {
+		container.SecurityContext = &k8sSecurity{Privileged: true}
+	}
+
+	resources := &k8sResources{
+		Limits:   map[string]string{},
+		Requests: map[string]string{},
+	}
+	if proc.MemLimit != 0 {
+		resources.Limits["memory"] = strconv.FormatInt(proc.MemLimit, 10)
+	}
+	if proc.CPUQuota != 0 {
+		// the quota is relative to the default 100ms period.
+		resources.Limits["cpu"] = fmt.Sprintf("%dm", proc.CPUQuota/100)
+	}
+	if proc.CPUShares != 0 {
+		resources.Requests["cpu"] = fmt.Sprintf("%dm", proc.CPUShares*1000/1024)
+	}
+	if len(resources.Limits) != 0 || len(resources.Requests) != 0 {
+		container.Resources = resources
+	}
+
+	var volumes []k8sVolume
+	for i, volume := range proc.Volumes {
+		src, dst, mode := volumeParts(volume)
+		mount := k8sMount{MountPath: dst, ReadOnly: mode == "ro"}
+		switch {
+		case src == "":
+			mount.Name = dnsName(fmt.Sprintf("%s-volume-%d", container.Name, i))
+			volumes = append(volumes, k8sVolume{Name: mount.Name, EmptyDir: &k8sEmptyDir{}})
+		case src[0] == '/':
+			mount.Name = dnsName(fmt.Sprintf("%s-host-%d", container.Name, i))
+			volumes = append(volumes, k8sVolume{Name: mount.Name, HostPath: &k8sHostPath{Path: src}})
+		default:
+			mount.Name = dnsName(src)
+			volumes = append(volumes, k8sVolume{Name: mount.Name, EmptyDir: &k8sEmptyDir{}})
+		}
+		container.VolumeMounts = append(container.VolumeMounts, mount)
+	}
+	for i, tmpfs := range proc.Tmpfs {
+		// the tmpfs options, if any, follow the path.
+		dst := strings.SplitN(tmpfs, ":", 2)[0]
+		name := dnsName(fmt.Sprintf("%s-tmpfs-%d", container.Name, i))
+		volumes = append(volumes, k8sVolume{Name: name, EmptyDir: &k8sEmptyDir{Medium: "Memory"}})
+		container.VolumeMounts = append(container.VolumeMounts, k8sMount{Name: name, MountPath: dst})
+	}
+	return container, volumes
+}
@@ -0,0 +1,201 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+// Shell writes the configuration as a POSIX shell script that runs each
+// step with the docker command line client. The steps run one at a
+// time, in order, and the networks, volumes and containers are removed
+// when the script exits. The script exits with the status of the first
+// failed step.
+func Shell(w io.Writer, config *backend.Config) error {
+	var (
+		buf        bytes.Buffer
+		containers []string
+	)
+	for _, proc := range steps(config) {
+		containers = append(containers, quote(proc.Name))
+	}
+
+	buf.WriteString("#!/bin/sh\n")
+	buf.WriteString("set -u\n\n")
+	buf.WriteString("cleanup() {\n")
+	if len(containers) != 0 {
+		fmt.Fprintf(&buf, "\tdocker rm -f %s >/dev/null 2>&1\n", strings.Join(containers, " "))
+	}
+	for _, network := range config.Networks {
+		fmt.Fprintf(&buf, "\tdocker network rm %s >/dev/null 2>&1\n", quote(network.Name))
+	}
+	for _, volume := range config.Volumes {
+		fmt.Fprintf(&buf, "\tdocker volume rm %s >/dev/null 2>&1\n", quote(volume.Name))
+	}
+	buf.WriteString("\treturn 0\n")
+	buf.WriteString("}\n")
+	buf.WriteString("trap cleanup EXIT\n")
+	buf.WriteString("trap 'exit 130' INT TERM\n\n")
+
+	for _, network := range config.Networks {
+		buf.WriteString("docker network create")
+		writeFlag(&buf, "--driver", network.Driver)
+		writeOpts(&buf, "--opt", network.DriverOpts)
+		fmt.Fprintf(&buf, " %s >/dev/null || exit 1\n", quote(network.Name))
+	}
+	for _, volume := range config.Volumes {
+		buf.WriteString("docker volume create")
+		writeFlag(&buf, "--driver", volume.Driver)
+		writeOpts(&buf, "--opt", volume.DriverOpts)
+		fmt.Fprintf(&buf, " %s >/dev/null || exit 1\n", quote(volume.Name))
+	}
+	buf.WriteString("\nstatus=0\n")
+
+	for _, stage := range config.Stages {
+		fmt.Fprintf(&buf, "\n# %s\n", stageName(stage))
+		for _, proc := range stage.Steps {
+			writeStep(&buf, proc)
+		}
+	}
+	buf.WriteString("\nexit $status\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeStep writes the commands that run the step. The step runs when
+// the pipeline status matches its on_success and on_failure settings.
+func writeStep(buf *bytes.Buffer, proc *backend.Step) {
+	var condition string
+	switch {
+	case proc.OnSuccess && proc.OnFailure:
+	case proc.OnFailure:
+		condition = `[ "$status" -ne 0 ]`
+	default:
+		condition = `[ "$status" -eq 0 ]`
+	}
+
+	indent := ""
+	if condition != "" {
+		fmt.Fprintf(buf, "if %s; then\n", condition)
+		indent = "\t"
+	}
+
+	name := quote(proc.Name)
+	if proc.Pull {
+		fmt.Fprintf(buf, "%sdocker pull %s\n", indent, quote(proc.Image))
+	}
+
+	fmt.Fprintf(buf, "%sdocker create", indent)
+	writeFlag(buf, "--name", proc.Name)
+	writeBool(buf, "--privileged", proc.Privileged)
+	writeFlag(buf, "--workdir", proc.WorkingDir)
+	writeOpts(buf, "--env", proc.Environment)
+	writeOpts(buf, "--label", proc.Labels)
+	writeList(buf, "--add-host", proc.ExtraHosts)
+	writeList(buf, "--volume", proc.Volumes)
+	writeList(buf, "--tmpfs", proc.Tmpfs)
+	writeList(buf, "--device", proc.Devices)
+	writeList(buf, "--dns", proc.DNS)
+	writeList(buf, "--dns-search", proc.DNSSearch)
+	writeInt(buf, "--memory-swap", proc.MemSwapLimit)
+	writeInt(buf, "--memory", proc.MemLimit)
+	writeInt(buf, "--shm-size", proc.ShmSize)
+	writeInt(buf, "--cpu-quota", proc.CPUQuota)
+	writeInt(buf, "--cpu-shares", proc.CPUShares)
+	writeFlag(buf, "--cpuset-cpus", proc.CPUSet)
+	writeFlag(buf, "--ipc", proc.IpcMode)
+	writeOpts(buf, "--sysctl", proc.Sysctls)
+	writeFlag(buf, "--stop-signal", proc.StopSignal)
+	writeInt(buf, "--stop-timeout", int64(proc.StopGrace.Seconds()))
+
+	// the first network is connected when the container is created,
+	// and the other networks before the container is started.
+	networks := proc.Networks
+	if proc.NetworkMode != "" {
+		writeFlag(buf, "--network", proc.NetworkMode)
+		networks = nil
+	} else if len(networks) != 0 {
+		writeFlag(buf, "--network", networks[0].Name)
+		writeList(buf, "--network-alias", networks[0].Aliases)
+		networks = networks[1:]
+	}
+
+	// the docker client only accepts the entrypoint executable, and its
+	// arguments are passed before the command.
+	args := proc.Command
+	if len(proc.Entrypoint) != 0 {
+		writeFlag(buf, "--entrypoint", proc.Entrypoint[0])
+		args = append(append([]string{}, proc.Entrypoint[1:]...), proc.Command...)
+	}
+	fmt.Fprintf(buf, " %s", quote(proc.Image))
+	for _, arg := range args {
+		fmt.Fprintf(buf, " %s", quote(arg))
+	}
+	buf.WriteString(" >/dev/null\n")
+
+	for _, conn := range networks {
+		fmt.Fprintf(buf, "%sdocker network connect", indent)
+		writeList(buf, "--alias", conn.Aliases)
+		fmt.Fprintf(buf, " %s %s\n", quote(conn.Name), name)
+	}
+
+	switch {
+	case proc.Detached:
+		fmt.Fprintf(buf, "%sdocker start %s >/dev/null\n", indent, name)
+	case proc.AllowFailure:
+		fmt.Fprintf(buf, "%sdocker start --attach %s\n", indent, name)
+	default:
+		fmt.Fprintf(buf, "%sdocker start --attach %s || status=$?\n", indent, name)
+	}
+
+	if condition != "" {
+		buf.WriteString("fi\n")
+	}
+}
+
+func stageName(stage *backend.Stage) string {
+	if stage.Alias != "" {
+		return stage.Alias
+	}
+	return stage.Name
+}
+
+func writeFlag(buf *bytes.Buffer, flag, value string) {
+	if value != "" {
+		fmt.Fprintf(buf, " %s %s", flag, quote(value))
+	}
+}
+
+func writeBool(buf *bytes.Buffer, flag string, value bool) {
+	if value {
+		fmt.Fprintf(buf, " %s", flag)
+	}
+}
+
+func writeInt(buf *bytes.Buffer, flag string, value int64) {
+	if value != 0 {
+		fmt.Fprintf(buf, " %s %s", flag, strconv.FormatInt(value, 10))
+	}
+}
+
+func writeList(buf *bytes.Buffer, flag string, values []string) {
+	for _, value := range values {
+		writeFlag(buf, flag, value)
+	}
+}
+
+func writeOpts(buf *bytes.Buffer, flag string, opts map[string]string) {
+	for _, k := range sortedKeys(opts) {
+		writeFlag(buf, flag, k+"="+opts[k])
+	}
+}
+
+// quote quotes the string for the shell.
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
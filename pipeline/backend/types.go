@@ -1,5 +1,7 @@
 package backend
 
+import "time"
+
 type (
 	// Config defines the runtime configuration of a pipeline.
 	Config struct {
@@ -11,9 +13,10 @@ type (
 
 	// Stage denotes a collection of one or more steps.
 	Stage struct {
-		Name  string  `json:"name,omitempty"`
-		Alias string  `json:"alias,omitempty"`
-		Steps []*Step `json:"steps,omitempty"`
+		Name     string  `json:"name,omitempty"`
+		Alias    string  `json:"alias,omitempty"`
+		Steps    []*Step `json:"steps,omitempty"`
+		FailFast bool    `json:"fail_fast,omitempty"`
 	}
 
 	// Step defines a container process.
@@ -44,10 +47,13 @@ type (
 		CPUSet       string            `json:"cpu_set,omitempty"`
 		OnFailure    bool              `json:"on_failure,omitempty"`
 		OnSuccess    bool              `json:"on_success,omitempty"`
+		AllowFailure bool              `json:"allow_failure,omitempty"`
 		AuthConfig   Auth              `json:"auth_config,omitempty"`
 		NetworkMode  string            `json:"network_mode,omitempty"`
 		IpcMode      string            `json:"ipc_mode,omitempty"`
 		Sysctls      map[string]string `json:"sysctls,omitempty"`
+		StopSignal   string            `json:"stop_signal,omitempty"`
+		StopGrace    time.Duration     `json:"stop_grace_period,omitempty"`
 	}
 
 	// Auth defines registry authentication credentials.
@@ -93,6 +99,24 @@ type (
 		Exited bool `json:"exited"`
 		// Container is oom killed, true or false
 		OOMKilled bool `json:"oom_killed"`
+		// Container resource usage, if sampled by the engine
+		Usage *Usage `json:"usage,omitempty"`
+	}
+
+	// Usage defines the resource usage of a container.
+	Usage struct {
+		// Peak memory usage in bytes
+		MemoryPeak uint64 `json:"memory_peak"`
+		// Total CPU time in seconds
+		CPUSeconds float64 `json:"cpu_seconds"`
+		// Bytes read from block devices
+		BlockRead uint64 `json:"block_read"`
+		// Bytes written to block devices
+		BlockWrite uint64 `json:"block_write"`
+		// Bytes received over the network
+		NetRx uint64 `json:"net_rx"`
+		// Bytes sent over the network
+		NetTx uint64 `json:"net_tx"`
 	}
 
 	// // State defines the pipeline and process state.
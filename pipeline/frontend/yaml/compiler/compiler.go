@@ -120,7 +120,7 @@ func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
 		config.Stages = append(config.Stages, stage)
 	} else if c.local == false {
 		for i, container := range conf.Clone.Containers {
-			if !container.Constraints.Match(c.metadata) {
+			if !c.decide("clone", container).Include {
 				continue
 			}
 			stage := new(backend.Stage)
@@ -144,7 +144,7 @@ func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
 		stage.Alias = "services"
 
 		for i, container := range conf.Services.Containers {
-			if !container.Constraints.Match(c.metadata) {
+			if !c.decide("services", container).Include {
 				continue
 			}
 
@@ -160,12 +160,9 @@ func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
 	var stage *backend.Stage
 	var group string
 	for i, container := range conf.Pipeline.Containers {
-		//Skip if local and should not run local
-		if c.local && !container.Constraints.Local.Bool() {
-			continue
-		}
-
-		if !container.Constraints.Match(c.metadata) {
+		// skip if excluded by the constraints, or if local and
+		// should not run local
+		if !c.decide("pipeline", container).Include {
 			continue
 		}
 
@@ -175,9 +172,15 @@ func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
 			stage = new(backend.Stage)
 			stage.Name = fmt.Sprintf("%s_stage_%v", c.prefix, i)
 			stage.Alias = container.Name
+			stage.FailFast = conf.FailFast
 			config.Stages = append(config.Stages, stage)
 		}
 
+		// a single step may enable fail fast for the entire group.
+		if container.FailFast {
+			stage.FailFast = true
+		}
+
 		name := fmt.Sprintf("%s_step_%d", c.prefix, i)
 		step := c.createProcess(name, container, "pipeline")
 		stage.Steps = append(stage.Steps, step)
@@ -1 +1,73 @@
 package compiler
+
+import (
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+	"github.com/cncd/pipeline/pipeline/frontend/yaml"
+)
+
+func TestExplain(t *testing.T) {
+	conf, err := yaml.ParseString(`
+pipeline:
+  build:
+    image: golang
+  deploy:
+    image: plugins/ssh
+    when:
+      branch: master
+  publish:
+    image: plugins/docker
+    when:
+      local: false
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := frontend.Metadata{
+		Curr: frontend.Build{Commit: frontend.Commit{Branch: "develop"}},
+	}
+	c := New(WithMetadata(metadata), WithLocal(true))
+
+	decisions := c.Explain(conf)
+	if len(decisions) != 3 {
+		t.Fatalf("Want a decision for each container, got %d", len(decisions))
+	}
+	if d := decisions[0]; !d.Include || len(d.Reasons()) != 0 {
+		t.Errorf("Want build included without reasons, got %+v %v", d, d.Reasons())
+	}
+	if d := decisions[1]; d.Include || len(d.Reasons()) != 1 || d.Reasons()[0] != `branch "develop" does not match master` {
+		t.Errorf("Want deploy excluded by the branch, got %v", d.Reasons())
+	}
+	if d := decisions[2]; d.Include || d.Reason != reasonLocal {
+		t.Errorf("Want publish excluded for local builds, got %+v", d)
+	}
+
+	var names []string
+	for _, stage := range c.Compile(conf).Stages {
+		for _, step := range stage.Steps {
+			names = append(names, step.Alias)
+		}
+	}
+	if len(names) != 1 || names[0] != "build" {
+		t.Errorf("Want compiled steps to agree with the decisions, got %v", names)
+	}
+}
+
+func TestExplainDefaultClone(t *testing.T) {
+	conf, err := yaml.ParseString(`
+pipeline:
+  build:
+    image: golang
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decisions := New().Explain(conf)
+	if len(decisions) != 2 {
+		t.Fatalf("Want a decision for the default clone step and the build, got %d", len(decisions))
+	}
+	if d := decisions[0]; d.Section != "clone" || !d.Include || d.Reason != reasonDefaultClone {
+		t.Errorf("Want default clone step included, got %+v", d)
+	}
+}
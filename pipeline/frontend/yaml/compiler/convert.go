@@ -174,7 +174,10 @@ func (c *Compiler) createProcess(name string, container *yaml.Container, section
 		OnFailure: (len(container.Constraints.Status.Include)+
 			len(container.Constraints.Status.Exclude) != 0) &&
 			container.Constraints.Status.Match("failure"),
-		NetworkMode: network_mode,
-		IpcMode:     ipc_mode,
+		NetworkMode:  network_mode,
+		IpcMode:      ipc_mode,
+		StopSignal:   container.StopSignal,
+		StopGrace:    container.StopGrace,
+		AllowFailure: container.Failure == "ignore",
 	}
 }
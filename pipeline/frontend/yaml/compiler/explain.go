@@ -0,0 +1,87 @@
+package compiler
+
+import "github.com/cncd/pipeline/pipeline/frontend/yaml"
+
+// Reasons a container is included or excluded regardless of its
+// constraints.
+const (
+	reasonLocalClone   = "clone is disabled for local builds"
+	reasonLocal        = "local is false"
+	reasonDefaultClone = "default clone step, no clone containers are defined"
+)
+
+// Decision explains why a container is included in, or excluded from,
+// the compiled pipeline.
+type Decision struct {
+	Section     string            `json:"section"`
+	Name        string            `json:"name"`
+	Include     bool              `json:"include"`
+	Reason      string            `json:"reason,omitempty"`
+	Constraints *yaml.Explanation `json:"constraints,omitempty"`
+}
+
+// Reasons returns the reasons for the decision. An excluded container
+// lists the constraints that do not match, and an included container
+// lists the constraints that matched a pattern.
+func (d *Decision) Reasons() []string {
+	var reasons []string
+	if d.Reason != "" {
+		reasons = append(reasons, d.Reason)
+	}
+	if d.Constraints == nil {
+		return reasons
+	}
+	for _, result := range d.Constraints.Results {
+		switch {
+		case d.Include && result.Decision == yaml.DecisionNone:
+		case !d.Include && result.Match:
+		default:
+			reasons = append(reasons, result.String())
+		}
+	}
+	return reasons
+}
+
+// Explain returns the decision for every clone, service and pipeline
+// container in the configuration, in the order they are defined. The
+// default clone step, added when no clone container is defined, is
+// reported first.
+func (c *Compiler) Explain(conf *yaml.Config) []Decision {
+	var decisions []Decision
+	if !c.local && len(conf.Clone.Containers) == 0 {
+		decisions = append(decisions, Decision{
+			Section: "clone",
+			Name:    "clone",
+			Include: true,
+			Reason:  reasonDefaultClone,
+		})
+	}
+	for _, container := range conf.Clone.Containers {
+		decisions = append(decisions, c.decide("clone", container))
+	}
+	for _, container := range conf.Services.Containers {
+		decisions = append(decisions, c.decide("services", container))
+	}
+	for _, container := range conf.Pipeline.Containers {
+		decisions = append(decisions, c.decide("pipeline", container))
+	}
+	return decisions
+}
+
+// decide returns the decision for the container in the section.
+func (c *Compiler) decide(section string, container *yaml.Container) Decision {
+	decision := Decision{
+		Section:     section,
+		Name:        container.Name,
+		Constraints: container.Constraints.Explain(c.metadata),
+	}
+	switch {
+	case c.local && section == "clone":
+		decision.Reason = reasonLocalClone
+	case c.local && section == "pipeline" && !container.Constraints.Local.Bool():
+		decision.Reason = reasonLocal
+	default:
+		decision.Include = decision.Constraints.Match
+	}
+	return decision
+}
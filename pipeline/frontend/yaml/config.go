@@ -22,6 +22,7 @@ type (
 		Networks  Networks
 		Volumes   Volumes
 		Labels    libcompose.SliceorMap
+		FailFast  bool `yaml:"fail_fast"`
 	}
 
 	// Workspace defines a pipeline workspace.
@@ -1,7 +1,10 @@
 package yaml
 
 import (
+	"fmt"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/cncd/pipeline/pipeline/frontend"
 	"github.com/cncd/pipeline/pipeline/frontend/yaml/types"
@@ -36,6 +39,57 @@ type (
 	}
 )
 
+// Decision defines how a constraint decided the match.
+type Decision string
+
+// Constraint decisions.
+const (
+	DecisionNone    Decision = "none"     // no patterns are defined
+	DecisionInclude Decision = "include"  // an include pattern matched
+	DecisionExclude Decision = "exclude"  // an exclude pattern matched
+	DecisionNoMatch Decision = "no_match" // no include pattern matched
+)
+
+// ConstraintResult defines the result of matching a single constraint.
+type ConstraintResult struct {
+	Field    string   `json:"field"`
+	Value    string   `json:"value"`
+	Match    bool     `json:"match"`
+	Decision Decision `json:"decision"`
+	Pattern  string   `json:"pattern,omitempty"`
+}
+
+// String returns a description of the result.
+func (r ConstraintResult) String() string {
+	switch r.Decision {
+	case DecisionInclude:
+		return fmt.Sprintf("%s %q matches %q", r.Field, r.Value, r.Pattern)
+	case DecisionExclude:
+		return fmt.Sprintf("%s %q is excluded by %q", r.Field, r.Value, r.Pattern)
+	case DecisionNoMatch:
+		return fmt.Sprintf("%s %q does not match %s", r.Field, r.Value, r.Pattern)
+	default:
+		return fmt.Sprintf("%s is not constrained", r.Field)
+	}
+}
+
+// Explanation defines the result of matching every constraint.
+type Explanation struct {
+	Match   bool               `json:"match"`
+	Results []ConstraintResult `json:"results"`
+}
+
+// Failed returns the results of the constraints that do not match.
+func (e *Explanation) Failed() []ConstraintResult {
+	var failed []ConstraintResult
+	for _, result := range e.Results {
+		if !result.Match {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
 // Match returns true if all constraints match the given input. If a single
 // constraint fails a false value is returned.
 func (c *Constraints) Match(metadata frontend.Metadata) bool {
@@ -49,19 +103,43 @@ func (c *Constraints) Match(metadata frontend.Metadata) bool {
 		c.Matrix.Match(metadata.Job.Matrix)
 }
 
+// Explain matches every constraint with the given input, and returns the
+// result of each constraint. The explanation matches if, and only if,
+// Match returns true.
+func (c *Constraints) Explain(metadata frontend.Metadata) *Explanation {
+	explanation := &Explanation{Match: true}
+	for _, check := range []struct {
+		field      string
+		constraint *Constraint
+		value      string
+	}{
+		{"platform", &c.Platform, metadata.Sys.Arch},
+		{"environment", &c.Environment, metadata.Curr.Target},
+		{"event", &c.Event, metadata.Curr.Event},
+		{"branch", &c.Branch, metadata.Curr.Commit.Branch},
+		{"repo", &c.Repo, metadata.Repo.Name},
+		{"ref", &c.Ref, metadata.Curr.Commit.Ref},
+		{"instance", &c.Instance, metadata.Sys.Host},
+	} {
+		result := check.constraint.Explain(check.value)
+		result.Field = check.field
+		explanation.add(result)
+	}
+	result := c.Matrix.Explain(metadata.Job.Matrix)
+	result.Field = "matrix"
+	explanation.add(result)
+	return explanation
+}
+
+func (e *Explanation) add(result ConstraintResult) {
+	e.Results = append(e.Results, result)
+	e.Match = e.Match && result.Match
+}
+
 // Match returns true if the string matches the include patterns and does not
 // match any of the exclude patterns.
 func (c *Constraint) Match(v string) bool {
-	if c.Excludes(v) {
-		return false
-	}
-	if c.Includes(v) {
-		return true
-	}
-	if len(c.Include) == 0 {
-		return true
-	}
-	return false
+	return c.Explain(v).Match
 }
 
 // Includes returns true if the string matches the include patterns.
@@ -84,6 +162,35 @@ func (c *Constraint) Excludes(v string) bool {
 	return false
 }
 
+// Explain returns the result of matching the string, and the pattern
+// that decided it.
+func (c *Constraint) Explain(v string) ConstraintResult {
+	result := ConstraintResult{Value: v}
+	for _, pattern := range c.Exclude {
+		if ok, _ := filepath.Match(pattern, v); ok {
+			result.Decision = DecisionExclude
+			result.Pattern = pattern
+			return result
+		}
+	}
+	for _, pattern := range c.Include {
+		if ok, _ := filepath.Match(pattern, v); ok {
+			result.Match = true
+			result.Decision = DecisionInclude
+			result.Pattern = pattern
+			return result
+		}
+	}
+	if len(c.Include) != 0 {
+		result.Decision = DecisionNoMatch
+		result.Pattern = strings.Join(c.Include, ", ")
+		return result
+	}
+	result.Match = true
+	result.Decision = DecisionNone
+	return result
+}
+
 // UnmarshalYAML unmarshals the constraint.
 func (c *Constraint) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var out1 = struct {
@@ -107,30 +214,44 @@ func (c *Constraint) UnmarshalYAML(unmarshal func(interface{}) error) error {
 // Match returns true if the params matches the include key values and does not
 // match any of the exclude key values.
 func (c *ConstraintMap) Match(params map[string]string) bool {
-	// when no includes or excludes automatically match
+	return c.Explain(params).Match
+}
+
+// Explain returns the result of matching the params, and the key
+// values that decided it.
+func (c *ConstraintMap) Explain(params map[string]string) ConstraintResult {
+	result := ConstraintResult{Value: joinMap(params)}
 	if len(c.Include) == 0 && len(c.Exclude) == 0 {
-		return true
+		result.Match = true
+		result.Decision = DecisionNone
+		return result
 	}
 	// exclusions are processed first. So we can include everything and then
 	// selectively include others.
 	if len(c.Exclude) != 0 {
 		var matches int
-
 		for key, val := range c.Exclude {
 			if params[key] == val {
 				matches++
 			}
 		}
 		if matches == len(c.Exclude) {
-			return false
+			result.Decision = DecisionExclude
+			result.Pattern = joinMap(c.Exclude)
+			return result
 		}
 	}
-	for key, val := range c.Include {
-		if params[key] != val {
-			return false
+	for _, key := range sortedKeys(c.Include) {
+		if params[key] != c.Include[key] {
+			result.Decision = DecisionNoMatch
+			result.Pattern = key + "=" + c.Include[key]
+			return result
 		}
 	}
-	return true
+	result.Match = true
+	result.Decision = DecisionInclude
+	result.Pattern = joinMap(c.Include)
+	return result
 }
 
 // UnmarshalYAML unmarshals the constraint map.
@@ -155,3 +276,21 @@ func (c *ConstraintMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	}
 	return nil
 }
+
+// joinMap returns the map as sorted, space separated key values.
+func joinMap(m map[string]string) string {
+	var pairs []string
+	for _, k := range sortedKeys(m) {
+		pairs = append(pairs, k+"="+m[k])
+	}
+	return strings.Join(pairs, " ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
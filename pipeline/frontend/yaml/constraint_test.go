@@ -147,6 +147,9 @@ func TestConstraint(t *testing.T) {
 		if got != want {
 			t.Errorf("Expect %q matches %q is %v", test.with, test.conf, want)
 		}
+		if got := c.Explain(test.with).Match; got != want {
+			t.Errorf("Expect %q explained match %q is %v", test.with, test.conf, want)
+		}
 	}
 }
 
@@ -263,6 +266,9 @@ func TestConstraintMap(t *testing.T) {
 		if got != want {
 			t.Errorf("Expect %q matches %q is %v", test.with, test.conf, want)
 		}
+		if got := c.Explain(test.with).Match; got != want {
+			t.Errorf("Expect %q explained match %q is %v", test.with, test.conf, want)
+		}
 	}
 }
 
@@ -351,6 +357,42 @@ func TestConstraints(t *testing.T) {
 		if got != want {
 			t.Errorf("Expect %+v matches %q is %v", test.with, test.conf, want)
 		}
+		if got := c.Explain(test.with).Match; got != want {
+			t.Errorf("Expect %+v explained match %q is %v", test.with, test.conf, want)
+		}
+	}
+}
+
+func TestConstraintsExplain(t *testing.T) {
+	c := parseConstraints("{ branch: [ master, release/* ], event: { exclude: pull_request }, matrix: { GO: 1.8 } }")
+	metadata := frontend.Metadata{
+		Curr: frontend.Build{
+			Event:  "pull_request",
+			Commit: frontend.Commit{Branch: "release/1.0"},
+		},
+		Job: frontend.Job{Matrix: map[string]string{"GO": "1.7"}},
+	}
+
+	explanation := c.Explain(metadata)
+	if explanation.Match {
+		t.Errorf("Want explanation not matched")
+	}
+	want := map[string]ConstraintResult{
+		"branch":   {Field: "branch", Value: "release/1.0", Match: true, Decision: DecisionInclude, Pattern: "release/*"},
+		"event":    {Field: "event", Value: "pull_request", Decision: DecisionExclude, Pattern: "pull_request"},
+		"matrix":   {Field: "matrix", Value: "GO=1.7", Decision: DecisionNoMatch, Pattern: "GO=1.8"},
+		"platform": {Field: "platform", Match: true, Decision: DecisionNone},
+	}
+	for _, result := range explanation.Results {
+		if w, ok := want[result.Field]; ok && result != w {
+			t.Errorf("Want %s result %+v, got %+v", result.Field, w, result)
+		}
+	}
+	if got := len(explanation.Failed()); got != 2 {
+		t.Errorf("Want 2 failed constraints, got %d", got)
+	}
+	if got, want := explanation.Failed()[0].String(), `event "pull_request" is excluded by "pull_request"`; got != want {
+		t.Errorf("Want description %s, got %s", want, got)
 	}
 }
 
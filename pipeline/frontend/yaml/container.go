@@ -2,6 +2,7 @@ package yaml
 
 import (
 	"fmt"
+	"time"
 
 	libcompose "github.com/docker/libcompose/yaml"
 	"gopkg.in/yaml.v2"
@@ -38,6 +39,8 @@ type (
 		Entrypoint    libcompose.Command        `yaml:"entrypoint,omitempty"`
 		Environment   libcompose.SliceorMap     `yaml:"environment,omitempty"`
 		ExtraHosts    []string                  `yaml:"extra_hosts,omitempty"`
+		Failure       string                    `yaml:"failure,omitempty"`
+		FailFast      bool                      `yaml:"fail_fast,omitempty"`
 		Group         string                    `yaml:"group,omitempty"`
 		Image         string                    `yaml:"image,omitempty"`
 		Isolation     string                    `yaml:"isolation,omitempty"`
@@ -52,6 +55,8 @@ type (
 		Privileged    bool                      `yaml:"privileged,omitempty"`
 		Pull          bool                      `yaml:"pull,omitempty"`
 		ShmSize       libcompose.MemStringorInt `yaml:"shm_size,omitempty"`
+		StopSignal    string                    `yaml:"stop_signal,omitempty"`
+		StopGrace     time.Duration             `yaml:"stop_grace_period,omitempty"`
 		Ulimits       libcompose.Ulimits        `yaml:"ulimits,omitempty"`
 		Volumes       libcompose.Volumes        `yaml:"volumes,omitempty"`
 		Secrets       Secrets                   `yaml:"secrets,omitempty"`
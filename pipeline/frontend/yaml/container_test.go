@@ -3,6 +3,7 @@ package yaml
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	libcompose "github.com/docker/libcompose/yaml"
 	"github.com/kr/pretty"
@@ -35,6 +36,8 @@ environment:
 extra_hosts:
  - somehost:162.242.195.82
  - otherhost:50.31.209.229
+fail_fast: true
+failure: ignore
 isolation: hyperv
 name: my-build-container
 network_mode: bridge
@@ -47,6 +50,8 @@ labels:
   com.example.type: build
   com.example.team: frontend
 shm_size: 1kb
+stop_signal: SIGINT
+stop_grace_period: 1m30s
 mem_limit: 1kb
 memswap_limit: 1kb
 mem_swappiness: 1kb
@@ -84,6 +89,8 @@ func TestUnmarshalContainer(t *testing.T) {
 		Entrypoint:    libcompose.Command{"/code/entrypoint.sh"},
 		Environment:   libcompose.SliceorMap{"RACK_ENV": "development", "SHOW": "true"},
 		ExtraHosts:    []string{"somehost:162.242.195.82", "otherhost:50.31.209.229"},
+		FailFast:      true,
+		Failure:       "ignore",
 		Image:         "golang:latest",
 		Isolation:     "hyperv",
 		Labels:        libcompose.SliceorMap{"com.example.type": "build", "com.example.team": "frontend"},
@@ -101,6 +108,8 @@ func TestUnmarshalContainer(t *testing.T) {
 		Pull:        true,
 		Privileged:  true,
 		ShmSize:     libcompose.MemStringorInt(1024),
+		StopSignal:  "SIGINT",
+		StopGrace:   90 * time.Second,
 		Tmpfs:       libcompose.Stringorslice{"/var/lib/test"},
 		Ulimits: libcompose.Ulimits{
 			Elements: []libcompose.Ulimit{
@@ -61,6 +61,9 @@ func (l *Linter) lint(containers []*yaml.Container, block uint8) error {
 		if err := l.lintCommands(container); err != nil {
 			return err
 		}
+		if err := l.lintFailure(container); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -92,6 +95,15 @@ func (l *Linter) lintCommands(c *yaml.Container) error {
 	return nil
 }
 
+func (l *Linter) lintFailure(c *yaml.Container) error {
+	switch c.Failure {
+	case "", "fail", "ignore":
+		return nil
+	default:
+		return fmt.Errorf("Invalid failure value %q, expected fail or ignore", c.Failure)
+	}
+}
+
 func (l *Linter) lintEntrypoint(c *yaml.Container) error {
 	if len(c.Entrypoint) != 0 {
 		return fmt.Errorf("Cannot override container entrypoint")
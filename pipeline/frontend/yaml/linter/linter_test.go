@@ -11,6 +11,7 @@ func TestLint(t *testing.T) {
 pipeline:
   build:
     image: docker
+    failure: ignore
     privileged: true
     network_mode: host
     volumes:
@@ -95,6 +96,10 @@ func TestLintErrors(t *testing.T) {
 			from: "pipeline: { build: { image: golang, sysctls: [ net.core.somaxconn=1024 ] }  }",
 			want: "Insufficient privileges to use sysctls",
 		},
+		{
+			from: "pipeline: { build: { image: golang, failure: skip }  }",
+			want: "Invalid failure value \"skip\", expected fail or ignore",
+		},
 		// cannot override entypoint, command for script steps
 		{
 			from: "pipeline: { build: { image: golang, commands: [ 'go build' ], entrypoint: [ '/bin/bash' ] } }",
@@ -0,0 +1,255 @@
+// Package metrics provides counters, gauges and histograms exposed in the
+// Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds a collection of metrics.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// NewRegistry returns a new metrics registry.
+func NewRegistry() *Registry {
+	return new(Registry)
+}
+
+// Counter registers a counter with the given name, help text and label
+// names.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, "counter", labels, nil)}
+}
+
+// Gauge registers a gauge with the given name, help text and label
+// names.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, "gauge", labels, nil)}
+}
+
+// Histogram registers a histogram with the given name, help text, upper
+// bucket bounds and label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{r.register(name, help, "histogram", labels, buckets)}
+}
+
+func (r *Registry) register(name, help, kind string, labels []string, buckets []float64) *metric {
+	m := &metric{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  map[string]*series{},
+	}
+	r.mu.Lock()
+	r.metrics = append(r.metrics, m)
+	r.mu.Unlock()
+	return m
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: bufio.NewWriter(w)}
+
+	r.mu.Lock()
+	metrics := append([]*metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		m.write(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP writes the metrics to the response in the Prometheus text
+// format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WriteTo(w)
+}
+
+// Counter is a metric that only increases.
+type Counter struct {
+	m *metric
+}
+
+// Inc increments the counter with the given label values by one.
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add adds v to the counter with the given label values. Negative
+// values are ignored.
+func (c *Counter) Add(v float64, labels ...string) {
+	if v < 0 {
+		return
+	}
+	c.m.update(labels, func(s *series) { s.value += v })
+}
+
+// Gauge is a metric that can go up and down.
+type Gauge struct {
+	m *metric
+}
+
+// Set sets the gauge with the given label values to v.
+func (g *Gauge) Set(v float64, labels ...string) {
+	g.m.update(labels, func(s *series) { s.value = v })
+}
+
+// Add adds v, which may be negative, to the gauge with the given label
+// values.
+func (g *Gauge) Add(v float64, labels ...string) {
+	g.m.update(labels, func(s *series) { s.value += v })
+}
+
+// Histogram is a metric that samples observations in buckets.
+type Histogram struct {
+	m *metric
+}
+
+// Observe adds the observation to the histogram with the given label
+// values.
+func (h *Histogram) Observe(v float64, labels ...string) {
+	h.m.update(labels, func(s *series) {
+		for i, upper := range h.m.buckets {
+			if v <= upper {
+				s.buckets[i]++
+			}
+		}
+		s.count++
+		s.value += v
+	})
+}
+
+//
+// metric storage and text encoding.
+//
+
+type metric struct {
+	mu      sync.Mutex
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labels  []string
+	value   float64
+	count   uint64
+	buckets []uint64
+}
+
+func (m *metric) update(labels []string, fn func(*series)) {
+	if len(labels) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s: want %d label values, got %d", m.name, len(m.labels), len(labels)))
+	}
+	key := strings.Join(labels, "\xff")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{
+			labels:  append([]string(nil), labels...),
+			buckets: make([]uint64, len(m.buckets)),
+		}
+		m.series[key] = s
+	}
+	fn(s)
+}
+
+func (m *metric) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+	var keys []string
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := m.series[key]
+		if m.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, m.format(s.labels, ""), formatFloat(s.value))
+			continue
+		}
+		for i, upper := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.format(s.labels, formatFloat(upper)), s.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.format(s.labels, "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, m.format(s.labels, ""), formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, m.format(s.labels, ""), s.count)
+	}
+}
+
+// format returns the label set of the series, including the histogram
+// bucket upper bound if not empty.
+func (m *metric) format(values []string, le string) string {
+	var pairs []string
+	for i, name := range m.labels {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+// countWriter counts the bytes written and records the first error.
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	polls := r.Counter("queue_polls_total", "Total queue polls.")
+	errors := r.Counter("rpc_errors_total", "Total rpc errors.", "method", "transport")
+	running := r.Gauge("pipelines_running", "Running pipelines.")
+	pulls := r.Histogram("pull_seconds", "Image pull durations.", []float64{1, 0.5})
+
+	polls.Inc()
+	polls.Add(2)
+	polls.Add(-1)
+	errors.Inc("next", "websocket")
+	errors.Inc("log", `web"socket`)
+	running.Add(2)
+	running.Add(-1)
+	pulls.Observe(0.25)
+	pulls.Observe(0.75)
+	pulls.Observe(3)
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP queue_polls_total Total queue polls.
+# TYPE queue_polls_total counter
+queue_polls_total 3
+# HELP rpc_errors_total Total rpc errors.
+# TYPE rpc_errors_total counter
+rpc_errors_total{method="log",transport="web\"socket"} 1
+rpc_errors_total{method="next",transport="websocket"} 1
+# HELP pipelines_running Running pipelines.
+# TYPE pipelines_running gauge
+pipelines_running 1
+# HELP pull_seconds Image pull durations.
+# TYPE pull_seconds histogram
+pull_seconds_bucket{le="0.5"} 1
+pull_seconds_bucket{le="1"} 2
+pull_seconds_bucket{le="+Inf"} 3
+pull_seconds_sum 4
+pull_seconds_count 3
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Want metrics\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestRegistryLabelMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Want panic when label values do not match the label names")
+		}
+	}()
+	NewRegistry().Counter("rpc_errors_total", "", "method").Inc()
+}
+
+func TestRegistryHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("polls_total", "").Inc()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if got := w.Header().Get("Content-Type"); got != "text/plain; version=0.0.4" {
+		t.Errorf("Want prometheus text content type, got %s", got)
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte("polls_total 1")) {
+		t.Errorf("Want metrics written to the response")
+	}
+}
@@ -0,0 +1,44 @@
+package coverage
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+type (
+	coberturaReport struct {
+		Packages []coberturaPackage `xml:"packages>package"`
+	}
+
+	coberturaPackage struct {
+		Classes []coberturaClass `xml:"classes>class"`
+	}
+
+	coberturaClass struct {
+		Filename string          `xml:"filename,attr"`
+		Lines    []coberturaLine `xml:"lines>line"`
+	}
+
+	coberturaLine struct {
+		Number int `xml:"number,attr"`
+		Hits   int `xml:"hits,attr"`
+	}
+)
+
+// ParseCobertura parses a Cobertura XML report. The line coverage of
+// classes defined in the same file is merged.
+func ParseCobertura(r io.Reader) (*Report, error) {
+	in := new(coberturaReport)
+	if err := xml.NewDecoder(r).Decode(in); err != nil {
+		return nil, err
+	}
+	parsed := lines{}
+	for _, pkg := range in.Packages {
+		for _, class := range pkg.Classes {
+			for _, line := range class.Lines {
+				parsed.add(class.Filename, line.Number, line.Hits)
+			}
+		}
+	}
+	return parsed.report(), nil
+}
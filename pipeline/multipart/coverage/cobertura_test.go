@@ -0,0 +1,48 @@
+package coverage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCobertura(t *testing.T) {
+	report, err := ParseCobertura(strings.NewReader(sampleCobertura))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 1 {
+		t.Fatalf("Want classes in the same file merged, got %d files", len(report.Files))
+	}
+	if got, want := lineHits(report.Files[0]), []int{2, 0, 5}; !equalHits(got, want) {
+		t.Errorf("Want line coverage %v, got %v", want, got)
+	}
+	if got, want := report.Metrics.TotalLines, 3; got != want {
+		t.Errorf("Want %d total lines, got %d", want, got)
+	}
+
+	if _, err := ParseCobertura(strings.NewReader("<coverage>")); err == nil {
+		t.Errorf("Want error parsing invalid xml")
+	}
+}
+
+var sampleCobertura = `<?xml version="1.0" ?>
+<coverage line-rate="0.66" version="1.9">
+  <packages>
+    <package name="app">
+      <classes>
+        <class name="App" filename="app/app.py">
+          <lines>
+            <line number="1" hits="2"/>
+            <line number="2" hits="0"/>
+          </lines>
+        </class>
+        <class name="Helper" filename="app/app.py">
+          <lines>
+            <line number="3" hits="5"/>
+          </lines>
+        </class>
+      </classes>
+    </package>
+  </packages>
+</coverage>
+`
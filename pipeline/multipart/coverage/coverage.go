@@ -41,6 +41,58 @@ type (
 	}
 )
 
+// Compute computes the coverage metrics of each file, and the total
+// metrics of the report, from the line coverage. Lines with a nil
+// coverage value are not relevant, and are not counted.
+func (r *Report) Compute() {
+	r.Metrics = Metrics{}
+	var hits int
+	for i := range r.Files {
+		file := &r.Files[i]
+		file.compute()
+		r.Metrics.CoveredLines += file.CoveredLines
+		r.Metrics.TotalLines += file.TotalLines
+		hits += file.hits()
+	}
+	if r.Metrics.TotalLines != 0 {
+		r.Metrics.Covered = percent(r.Metrics.CoveredLines, r.Metrics.TotalLines)
+		r.Metrics.CoveredStrength = float64(hits) / float64(r.Metrics.TotalLines)
+	}
+}
+
+func (f *File) compute() {
+	f.CoveredLines, f.TotalLines = 0, 0
+	f.Covered, f.CoveredStrength = 0, 0
+	for _, count := range f.Coverage {
+		if count == nil {
+			continue
+		}
+		f.TotalLines++
+		if *count > 0 {
+			f.CoveredLines++
+		}
+	}
+	if f.TotalLines != 0 {
+		f.Covered = percent(f.CoveredLines, f.TotalLines)
+		f.CoveredStrength = float64(f.hits()) / float64(f.TotalLines)
+	}
+}
+
+// hits returns the total number of hits of the relevant lines.
+func (f *File) hits() int {
+	var hits int
+	for _, count := range f.Coverage {
+		if count != nil {
+			hits += *count
+		}
+	}
+	return hits
+}
+
+func percent(n, total int) float64 {
+	return float64(n) / float64(total) * 100
+}
+
 // WriteTo writes the report to multipart.Writer w.
 func (r *Report) WriteTo(w *multipart.Writer) error {
 	header := textproto.MIMEHeader{}
@@ -0,0 +1,45 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseGo parses a Go coverage profile, as written by the go test
+// -coverprofile flag. Each line of a block is covered by the number of
+// times the block was executed.
+func ParseGo(r io.Reader) (*Report, error) {
+	var (
+		scanner = bufio.NewScanner(r)
+		parsed  = lines{}
+		n       int
+	)
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+
+		// name.go:line.column,line.column statements count
+		i := strings.LastIndex(line, ":")
+		if i == -1 {
+			return nil, fmt.Errorf("coverage: line %d: invalid block %q", n, line)
+		}
+		var startLine, startCol, endLine, endCol, stmts, count int
+		_, err := fmt.Sscanf(line[i+1:], "%d.%d,%d.%d %d %d",
+			&startLine, &startCol, &endLine, &endCol, &stmts, &count)
+		if err != nil || endLine < startLine {
+			return nil, fmt.Errorf("coverage: line %d: invalid block %q", n, line)
+		}
+		for l := startLine; l <= endLine; l++ {
+			parsed.add(line[:i], l, count)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return parsed.report(), nil
+}
@@ -0,0 +1,69 @@
+package coverage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGo(t *testing.T) {
+	report, err := ParseGo(strings.NewReader(sampleGo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 2 {
+		t.Fatalf("Want 2 files, got %d", len(report.Files))
+	}
+
+	file := report.Files[0]
+	if got, want := file.Name, "github.com/cncd/pipeline/a.go"; got != want {
+		t.Errorf("Want file %s, got %s", want, got)
+	}
+	if got, want := lineHits(file), []int{-1, -1, 1, 1, 1, -1, 0, 0}; !equalHits(got, want) {
+		t.Errorf("Want line coverage %v, got %v", want, got)
+	}
+	if got, want := file.CoveredLines, 3; got != want {
+		t.Errorf("Want %d covered lines, got %d", want, got)
+	}
+	if got, want := report.Metrics.TotalLines, 6; got != want {
+		t.Errorf("Want %d total lines, got %d", want, got)
+	}
+	if got, want := report.Metrics.Covered, 50.0; got != want {
+		t.Errorf("Want %.2f%% covered, got %.2f%%", want, got)
+	}
+
+	if _, err := ParseGo(strings.NewReader("mode: set\na.go:1.1 1")); err == nil {
+		t.Errorf("Want error parsing an invalid block")
+	}
+}
+
+var sampleGo = `mode: set
+github.com/cncd/pipeline/a.go:3.10,5.2 2 1
+github.com/cncd/pipeline/a.go:7.10,8.2 1 0
+github.com/cncd/pipeline/b.go:1.1,1.20 1 0
+`
+
+// lineHits returns the hits of each line, with -1 for the lines that
+// are not relevant.
+func lineHits(file File) []int {
+	var hits []int
+	for _, count := range file.Coverage {
+		if count == nil {
+			hits = append(hits, -1)
+		} else {
+			hits = append(hits, *count)
+		}
+	}
+	return hits
+}
+
+func equalHits(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseLCOV parses an LCOV tracefile. The line coverage is read from the
+// DA records of each source file.
+func ParseLCOV(r io.Reader) (*Report, error) {
+	var (
+		scanner = bufio.NewScanner(r)
+		parsed  = lines{}
+		name    string
+		n       int
+	)
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			name = strings.TrimPrefix(line, "SF:")
+		case line == "end_of_record":
+			name = ""
+		case strings.HasPrefix(line, "DA:"):
+			// DA:line,hits[,checksum]
+			parts := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if name == "" || len(parts) < 2 {
+				return nil, fmt.Errorf("coverage: line %d: invalid record %q", n, line)
+			}
+			number, err := strconv.Atoi(parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("coverage: line %d: invalid record %q", n, line)
+			}
+			hits, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("coverage: line %d: invalid record %q", n, line)
+			}
+			parsed.add(name, number, hits)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return parsed.report(), nil
+}
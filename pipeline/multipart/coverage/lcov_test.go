@@ -0,0 +1,41 @@
+package coverage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLCOV(t *testing.T) {
+	report, err := ParseLCOV(strings.NewReader(sampleLCOV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 2 {
+		t.Fatalf("Want 2 files, got %d", len(report.Files))
+	}
+	if got, want := lineHits(report.Files[0]), []int{4, 4, -1, 0}; !equalHits(got, want) {
+		t.Errorf("Want line coverage %v, got %v", want, got)
+	}
+	if got, want := report.Files[0].CoveredStrength, 8.0/3; got != want {
+		t.Errorf("Want covered strength %f, got %f", want, got)
+	}
+	if got, want := report.Metrics.CoveredLines, 3; got != want {
+		t.Errorf("Want %d covered lines, got %d", want, got)
+	}
+
+	if _, err := ParseLCOV(strings.NewReader("DA:1,1\n")); err == nil {
+		t.Errorf("Want error parsing a record without a source file")
+	}
+}
+
+var sampleLCOV = `TN:
+SF:src/index.js
+FN:1,main
+DA:1,4
+DA:2,4
+DA:4,0
+end_of_record
+SF:src/util.js
+DA:1,1
+end_of_record
+`
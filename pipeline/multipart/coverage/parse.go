@@ -0,0 +1,53 @@
+package coverage
+
+import "sort"
+
+// lines records the number of hits of each line of each file.
+type lines map[string]map[int]int
+
+// add records the hits of the line. Lines recorded more than once keep
+// the highest number of hits.
+func (l lines) add(name string, line, hits int) {
+	if line < 1 {
+		return
+	}
+	file, ok := l[name]
+	if !ok {
+		file = map[int]int{}
+		l[name] = file
+	}
+	if prev, ok := file[line]; !ok || hits > prev {
+		file[line] = hits
+	}
+}
+
+// report returns the coverage report of the recorded lines, with the
+// files sorted by name.
+func (l lines) report() *Report {
+	var names []string
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := &Report{Files: []File{}}
+	for _, name := range names {
+		var last int
+		for line := range l[name] {
+			if line > last {
+				last = line
+			}
+		}
+		file := File{
+			Name:     name,
+			Coverage: make([]*int, last),
+		}
+		for line, hits := range l[name] {
+			hits := hits
+			file.Coverage[line-1] = &hits
+		}
+		report.Files = append(report.Files, file)
+	}
+	report.Compute()
+	return report
+}
@@ -0,0 +1,47 @@
+package coverage
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+
+	"github.com/cncd/pipeline/pipeline/multipart"
+)
+
+// ReadFrom reads the coverage reports from the parts of the multipart
+// reader. Parts that are not coverage reports are skipped.
+func ReadFrom(r multipart.Reader) ([]*Report, error) {
+	var reports []*Report
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			return reports, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !IsReport(part) {
+			continue
+		}
+		report, err := Decode(part)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+}
+
+// IsReport returns true if the part is a coverage report.
+func IsReport(part multipart.Part) bool {
+	mediatype, _, _ := mime.ParseMediaType(part.Header().Get("Content-Type"))
+	return mediatype == MimeType
+}
+
+// Decode decodes the coverage report from the part.
+func Decode(part multipart.Part) (*Report, error) {
+	report := new(Report)
+	if err := json.NewDecoder(part).Decode(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
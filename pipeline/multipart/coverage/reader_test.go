@@ -0,0 +1,41 @@
+package coverage
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"testing"
+
+	pipeline "github.com/cncd/pipeline/pipeline/multipart"
+)
+
+func TestReadFrom(t *testing.T) {
+	report, err := ParseLCOV(strings.NewReader(sampleLCOV))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	w.SetBoundary("boundary")
+	text, _ := w.CreatePart(map[string][]string{"Content-Type": {"text/plain"}})
+	text.Write([]byte("hello world"))
+	if err := report.WriteTo(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	in := fmt.Sprintf("PIPELINE\nContent-Type: multipart/mixed; boundary=boundary\n\n%s", buf.String())
+	reports, err := ReadFrom(pipeline.New(strings.NewReader(in)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("Want 1 coverage report, got %d", len(reports))
+	}
+	if !reflect.DeepEqual(reports[0], report) {
+		t.Errorf("Want report %+v, got %+v", report, reports[0])
+	}
+}
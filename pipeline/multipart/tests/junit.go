@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type (
+	junitSuite struct {
+		XMLName   xml.Name
+		Name      string       `xml:"name,attr"`
+		Time      string       `xml:"time,attr"`
+		Suites    []junitSuite `xml:"testsuite"`
+		Cases     []junitCase  `xml:"testcase"`
+		SystemOut string       `xml:"system-out"`
+		SystemErr string       `xml:"system-err"`
+	}
+
+	junitCase struct {
+		Name      string       `xml:"name,attr"`
+		Classname string       `xml:"classname,attr"`
+		Time      string       `xml:"time,attr"`
+		Failure   *junitResult `xml:"failure"`
+		Error     *junitResult `xml:"error"`
+		Skipped   *junitResult `xml:"skipped"`
+		SystemOut string       `xml:"system-out"`
+		SystemErr string       `xml:"system-err"`
+	}
+
+	junitResult struct {
+		Type    string `xml:"type,attr"`
+		Message string `xml:"message,attr"`
+		Output  string `xml:",chardata"`
+	}
+)
+
+// ParseJUnit parses a JUnit XML report. The root element is either a
+// testsuites element, or a single testsuite element. Nested suites are
+// flattened.
+func ParseJUnit(r io.Reader) (*Report, error) {
+	root := new(junitSuite)
+	if err := xml.NewDecoder(r).Decode(root); err != nil {
+		return nil, err
+	}
+	report := new(Report)
+	if root.XMLName.Local == "testsuites" {
+		for _, suite := range root.Suites {
+			report.add(suite)
+		}
+	} else {
+		report.add(*root)
+	}
+	report.Compute()
+	return report, nil
+}
+
+// add adds the suite, and the nested suites, to the report.
+func (r *Report) add(in junitSuite) {
+	suite := &Suite{
+		Name:     in.Name,
+		Duration: parseTime(in.Time),
+		Cases:    []*Case{},
+		Stdout:   strings.TrimSpace(in.SystemOut),
+		Stderr:   strings.TrimSpace(in.SystemErr),
+	}
+	for _, in := range in.Cases {
+		c := &Case{
+			Name:      in.Name,
+			Classname: in.Classname,
+			Status:    StatusPassed,
+			Duration:  parseTime(in.Time),
+			Stdout:    strings.TrimSpace(in.SystemOut),
+			Stderr:    strings.TrimSpace(in.SystemErr),
+		}
+		switch {
+		case in.Failure != nil:
+			c.Status = StatusFailed
+			c.Failure = in.Failure.failure()
+		case in.Error != nil:
+			c.Status = StatusError
+			c.Failure = in.Error.failure()
+		case in.Skipped != nil:
+			c.Status = StatusSkipped
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	if len(suite.Cases) != 0 || len(in.Suites) == 0 {
+		r.Suites = append(r.Suites, suite)
+	}
+	for _, nested := range in.Suites {
+		r.add(nested)
+	}
+}
+
+func (r *junitResult) failure() *Failure {
+	return &Failure{
+		Type:    r.Type,
+		Message: r.Message,
+		Output:  strings.TrimSpace(r.Output),
+	}
+}
+
+// parseTime parses the duration in seconds. Some reporters format the
+// duration with thousands separators.
+func parseTime(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.Replace(s, ",", "", -1), 64)
+	return f
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJUnit(t *testing.T) {
+	report, err := ParseJUnit(strings.NewReader(sampleJUnit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Suites) != 2 {
+		t.Fatalf("Want 2 suites, got %d", len(report.Suites))
+	}
+
+	suite := report.Suites[0]
+	if got, want := suite.Name, "com.example.AppTest"; got != want {
+		t.Errorf("Want suite %s, got %s", want, got)
+	}
+	if got, want := suite.Duration, 1234.5; got != want {
+		t.Errorf("Want suite duration %f, got %f", want, got)
+	}
+	if got, want := suite.Stdout, "starting"; got != want {
+		t.Errorf("Want suite stdout %q, got %q", want, got)
+	}
+
+	failed := suite.Cases[1]
+	if failed.Status != StatusFailed || failed.Failure == nil {
+		t.Fatalf("Want failed test case, got %+v", failed)
+	}
+	if got, want := failed.Failure.Message, "expected 1, got 2"; got != want {
+		t.Errorf("Want failure message %q, got %q", want, got)
+	}
+	if got, want := failed.Failure.Output, "at AppTest.java:12"; got != want {
+		t.Errorf("Want failure output %q, got %q", want, got)
+	}
+
+	want := Summary{Total: 4, Passed: 1, Failed: 1, Skipped: 1, Errors: 1, Duration: 1235}
+	if report.Summary != want {
+		t.Errorf("Want summary %+v, got %+v", want, report.Summary)
+	}
+}
+
+func TestParseJUnitSuite(t *testing.T) {
+	report, err := ParseJUnit(strings.NewReader(`<testsuite name="single"><testcase name="a"/></testsuite>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Suites) != 1 || report.Suites[0].Name != "single" {
+		t.Errorf("Want a single testsuite root parsed, got %+v", report.Suites)
+	}
+	if _, err := ParseJUnit(strings.NewReader("<testsuites>")); err == nil {
+		t.Errorf("Want error parsing invalid xml")
+	}
+}
+
+var sampleJUnit = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="com.example.AppTest" time="1,234.5">
+    <system-out>starting</system-out>
+    <testcase name="testAdd" classname="com.example.AppTest" time="0.5"/>
+    <testcase name="testSub" classname="com.example.AppTest" time="0.25">
+      <failure message="expected 1, got 2" type="AssertionError">
+        at AppTest.java:12
+      </failure>
+    </testcase>
+    <testcase name="testMul" classname="com.example.AppTest">
+      <skipped/>
+    </testcase>
+  </testsuite>
+  <testsuite name="com.example.DbTest" time="0.5">
+    <testcase name="testConnect" classname="com.example.DbTest">
+      <error message="connection refused" type="IOException"/>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
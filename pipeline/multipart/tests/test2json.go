@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// test2jsonEvent defines an event written by go test -json.
+type test2jsonEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// ParseTest2JSON parses the output of go test -json. Each package is a
+// suite, and each test without subtests is a test case. A test with
+// subtests is only reported when it fails while its subtests do not.
+// Lines that are not json events, such as build output, are ignored. A
+// package that fails without a failing test, for example because it
+// does not build, panics in TestMain or times out, is reported as a
+// failed test case named after the package.
+func ParseTest2JSON(r io.Reader) (*Report, error) {
+	var (
+		report  = new(Report)
+		suites  = map[string]*Suite{}
+		cases   = map[string]*Case{}
+		failed  = map[*Suite]bool{}
+		scanner = bufio.NewScanner(r)
+		n       int
+	)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		n++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		event := new(test2jsonEvent)
+		if err := json.Unmarshal(line, event); err != nil {
+			return nil, fmt.Errorf("tests: line %d: %s", n, err)
+		}
+		if event.Package == "" {
+			continue
+		}
+
+		suite, ok := suites[event.Package]
+		if !ok {
+			suite = &Suite{Name: event.Package, Cases: []*Case{}}
+			suites[event.Package] = suite
+			report.Suites = append(report.Suites, suite)
+		}
+
+		if event.Test == "" {
+			switch event.Action {
+			case "output":
+				suite.Stdout += event.Output
+			case "fail":
+				failed[suite] = true
+				suite.Duration = event.Elapsed
+			case "pass", "skip":
+				suite.Duration = event.Elapsed
+			}
+			continue
+		}
+
+		key := event.Package + "\x00" + event.Test
+		c, ok := cases[key]
+		if !ok {
+			c = &Case{Name: event.Test, Classname: event.Package}
+			cases[key] = c
+			suite.Cases = append(suite.Cases, c)
+		}
+		switch event.Action {
+		case "output":
+			c.Stdout += event.Output
+		case "pass":
+			c.Status = StatusPassed
+			c.Duration = event.Elapsed
+		case "skip":
+			c.Status = StatusSkipped
+			c.Duration = event.Elapsed
+		case "fail":
+			c.Status = StatusFailed
+			c.Duration = event.Elapsed
+			c.Failure = &Failure{Message: "test failed"}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// tests without a result did not complete, for example because
+	// the test binary panicked or timed out. The output of a failed
+	// test is reported with the failure.
+	for _, c := range cases {
+		if c.Status == "" {
+			c.Status = StatusFailed
+			c.Failure = &Failure{Message: "test did not complete"}
+		}
+		if c.Failure != nil {
+			c.Failure.Output, c.Stdout = c.Stdout, ""
+		}
+	}
+	for _, suite := range report.Suites {
+		suite.Cases = leafCases(suite.Cases)
+		if failed[suite] && !hasFailure(suite) {
+			suite.Cases = append(suite.Cases, &Case{
+				Name:      suite.Name,
+				Classname: suite.Name,
+				Status:    StatusFailed,
+				Duration:  suite.Duration,
+				Failure: &Failure{
+					Message: "package failed",
+					Output:  suite.Stdout,
+				},
+			})
+			suite.Stdout = ""
+		}
+	}
+	report.Compute()
+	return report, nil
+}
+
+// leafCases returns the test cases without the tests that have subtests,
+// so that a failed subtest is not counted again as the failure of its
+// parent. A parent test that fails while none of its subtests fail is
+// kept.
+func leafCases(cases []*Case) []*Case {
+	var (
+		parents     = map[string]bool{}
+		failedBelow = map[string]bool{}
+		leaves      = []*Case{}
+	)
+	for _, c := range cases {
+		for name := c.Name; strings.Contains(name, "/"); {
+			name = name[:strings.LastIndex(name, "/")]
+			parents[name] = true
+			if c.Status == StatusFailed {
+				failedBelow[name] = true
+			}
+		}
+	}
+	for _, c := range cases {
+		if parents[c.Name] && (c.Status != StatusFailed || failedBelow[c.Name]) {
+			continue
+		}
+		leaves = append(leaves, c)
+	}
+	return leaves
+}
+
+// hasFailure returns true if a test case of the suite failed.
+func hasFailure(suite *Suite) bool {
+	for _, c := range suite.Cases {
+		if c.Status == StatusFailed || c.Status == StatusError {
+			return true
+		}
+	}
+	return false
+}
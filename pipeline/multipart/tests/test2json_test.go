@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTest2JSON(t *testing.T) {
+	report, err := ParseTest2JSON(strings.NewReader(sampleTest2JSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Suites) != 1 {
+		t.Fatalf("Want 1 suite, got %d", len(report.Suites))
+	}
+	suite := report.Suites[0]
+	if got, want := suite.Duration, 0.015; got != want {
+		t.Errorf("Want suite duration %f, got %f", want, got)
+	}
+	if len(suite.Cases) != 4 {
+		t.Fatalf("Want 4 test cases, got %d", len(suite.Cases))
+	}
+
+	failed := suite.Cases[1]
+	if failed.Name != "TestFail" || failed.Status != StatusFailed {
+		t.Errorf("Want TestFail failed, got %+v", failed)
+	}
+	if got, want := failed.Failure.Output, "=== RUN   TestFail\n    a_test.go:9: boom\n--- FAIL: TestFail (0.00s)\n"; got != want {
+		t.Errorf("Want failure output %q, got %q", want, got)
+	}
+	if c := suite.Cases[3]; c.Status != StatusFailed || c.Failure.Message != "test did not complete" {
+		t.Errorf("Want incomplete test failed, got %+v", c)
+	}
+
+	want := Summary{Total: 4, Passed: 1, Failed: 2, Skipped: 1, Duration: 0.015}
+	if report.Summary != want {
+		t.Errorf("Want summary %+v, got %+v", want, report.Summary)
+	}
+
+	if _, err := ParseTest2JSON(strings.NewReader("{invalid")); err == nil {
+		t.Errorf("Want error parsing an invalid event")
+	}
+}
+
+func TestParseTest2JSONPackageFailure(t *testing.T) {
+	report, err := ParseTest2JSON(strings.NewReader(samplePackageFailure))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Suites) != 2 {
+		t.Fatalf("Want 2 suites, got %d", len(report.Suites))
+	}
+	if cases := report.Suites[0].Cases; len(cases) != 1 || cases[0].Status != StatusPassed {
+		t.Errorf("Want passing package reported without a failure, got %+v", cases)
+	}
+
+	cases := report.Suites[1].Cases
+	if len(cases) != 1 {
+		t.Fatalf("Want failed package reported as a test case, got %d cases", len(cases))
+	}
+	if c := cases[0]; c.Name != "github.com/example/b" || c.Status != StatusFailed || c.Failure.Output != "panic: init\nFAIL\n" {
+		t.Errorf("Want failed package test case with the package output, got %+v", c)
+	}
+	if report.Summary.Failed != 1 {
+		t.Errorf("Want package failure counted, got %+v", report.Summary)
+	}
+}
+
+func TestParseTest2JSONSubtests(t *testing.T) {
+	report, err := ParseTest2JSON(strings.NewReader(sampleSubtests))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := report.Suites[0].Cases
+	var names []string
+	for _, c := range cases {
+		names = append(names, c.Name)
+	}
+	if got, want := strings.Join(names, ","), "TestParent/pass,TestParent/fail,TestOwn,TestOwn/pass"; got != want {
+		t.Errorf("Want leaf tests and parents failing on their own %s, got %s", want, got)
+	}
+	if c := cases[1]; c.Stdout != "" || c.Failure.Output != "    a_test.go:12: boom\n" {
+		t.Errorf("Want failure output reported once, got stdout %q and failure %+v", c.Stdout, c.Failure)
+	}
+	if got, want := report.Summary.Failed, 2; got != want {
+		t.Errorf("Want %d failures, got %d", want, got)
+	}
+}
+
+var sampleSubtests = `{"Action":"run","Package":"github.com/example/a","Test":"TestParent"}
+{"Action":"run","Package":"github.com/example/a","Test":"TestParent/pass"}
+{"Action":"pass","Package":"github.com/example/a","Test":"TestParent/pass","Elapsed":0}
+{"Action":"run","Package":"github.com/example/a","Test":"TestParent/fail"}
+{"Action":"output","Package":"github.com/example/a","Test":"TestParent/fail","Output":"    a_test.go:12: boom\n"}
+{"Action":"fail","Package":"github.com/example/a","Test":"TestParent/fail","Elapsed":0}
+{"Action":"fail","Package":"github.com/example/a","Test":"TestParent","Elapsed":0}
+{"Action":"run","Package":"github.com/example/a","Test":"TestOwn"}
+{"Action":"run","Package":"github.com/example/a","Test":"TestOwn/pass"}
+{"Action":"pass","Package":"github.com/example/a","Test":"TestOwn/pass","Elapsed":0}
+{"Action":"fail","Package":"github.com/example/a","Test":"TestOwn","Elapsed":0}
+{"Action":"fail","Package":"github.com/example/a","Elapsed":0.01}
+`
+
+var samplePackageFailure = `{"ImportPath":"github.com/example/c","Action":"build-output","Output":"# github.com/example/c\n"}
+{"Action":"run","Package":"github.com/example/a","Test":"TestPass"}
+{"Action":"pass","Package":"github.com/example/a","Test":"TestPass","Elapsed":0.01}
+{"Action":"pass","Package":"github.com/example/a","Elapsed":0.01}
+{"Action":"output","Package":"github.com/example/b","Output":"panic: init\n"}
+{"Action":"output","Package":"github.com/example/b","Output":"FAIL\n"}
+{"Action":"fail","Package":"github.com/example/b","Elapsed":0.001}
+`
+
+var sampleTest2JSON = `# github.com/example/a
+{"Action":"run","Package":"github.com/example/a","Test":"TestPass"}
+{"Action":"output","Package":"github.com/example/a","Test":"TestPass","Output":"=== RUN   TestPass\n"}
+{"Action":"pass","Package":"github.com/example/a","Test":"TestPass","Elapsed":0.01}
+{"Action":"run","Package":"github.com/example/a","Test":"TestFail"}
+{"Action":"output","Package":"github.com/example/a","Test":"TestFail","Output":"=== RUN   TestFail\n"}
+{"Action":"output","Package":"github.com/example/a","Test":"TestFail","Output":"    a_test.go:9: boom\n"}
+{"Action":"output","Package":"github.com/example/a","Test":"TestFail","Output":"--- FAIL: TestFail (0.00s)\n"}
+{"Action":"fail","Package":"github.com/example/a","Test":"TestFail","Elapsed":0}
+{"Action":"run","Package":"github.com/example/a","Test":"TestSkip"}
+{"Action":"skip","Package":"github.com/example/a","Test":"TestSkip","Elapsed":0}
+{"Action":"run","Package":"github.com/example/a","Test":"TestHang"}
+{"Action":"output","Package":"github.com/example/a","Output":"FAIL\n"}
+{"Action":"fail","Package":"github.com/example/a","Elapsed":0.015}
+`
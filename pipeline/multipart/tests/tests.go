@@ -0,0 +1,113 @@
+// Package tests defines a normalized test report, parsed from the JUnit
+// XML and Go test2json formats, that is written as a part of a
+// multipart log stream.
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"mime/multipart"
+	"net/textproto"
+)
+
+// MimeType used by test reports.
+const MimeType = "application/json+tests"
+
+// Status defines the result of a test case.
+type Status string
+
+// Test case results.
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+	StatusError   Status = "error"
+)
+
+type (
+	// Report represents a test report.
+	Report struct {
+		Suites  []*Suite `json:"suites"`
+		Summary Summary  `json:"summary"`
+	}
+
+	// Suite represents a test suite.
+	Suite struct {
+		Name     string  `json:"name"`
+		Duration float64 `json:"duration"`
+		Cases    []*Case `json:"cases"`
+		Stdout   string  `json:"stdout,omitempty"`
+		Stderr   string  `json:"stderr,omitempty"`
+	}
+
+	// Case represents a single test case. The duration is in seconds.
+	Case struct {
+		Name      string   `json:"name"`
+		Classname string   `json:"classname,omitempty"`
+		Status    Status   `json:"status"`
+		Duration  float64  `json:"duration"`
+		Failure   *Failure `json:"failure,omitempty"`
+		Stdout    string   `json:"stdout,omitempty"`
+		Stderr    string   `json:"stderr,omitempty"`
+	}
+
+	// Failure represents the failure, or error, of a test case.
+	Failure struct {
+		Type    string `json:"type,omitempty"`
+		Message string `json:"message,omitempty"`
+		Output  string `json:"output,omitempty"`
+	}
+
+	// Summary represents the totals for all suites. The duration is in
+	// seconds.
+	Summary struct {
+		Total    int     `json:"total"`
+		Passed   int     `json:"passed"`
+		Failed   int     `json:"failed"`
+		Skipped  int     `json:"skipped"`
+		Errors   int     `json:"errors"`
+		Duration float64 `json:"duration"`
+	}
+)
+
+// Compute computes the report summary from the test cases.
+func (r *Report) Compute() {
+	r.Summary = Summary{}
+	for _, suite := range r.Suites {
+		r.Summary.Duration += suite.Duration
+		for _, c := range suite.Cases {
+			r.Summary.Total++
+			switch c.Status {
+			case StatusPassed:
+				r.Summary.Passed++
+			case StatusFailed:
+				r.Summary.Failed++
+			case StatusSkipped:
+				r.Summary.Skipped++
+			case StatusError:
+				r.Summary.Errors++
+			}
+		}
+	}
+}
+
+// WriteTo writes the report to multipart.Writer w.
+func (r *Report) WriteTo(w *multipart.Writer) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", MimeType)
+	header.Set("X-Tests-Total", strconv.Itoa(r.Summary.Total))
+	header.Set("X-Tests-Passed", strconv.Itoa(r.Summary.Passed))
+	header.Set("X-Tests-Failed", strconv.Itoa(r.Summary.Failed))
+	header.Set("X-Tests-Skipped", strconv.Itoa(r.Summary.Skipped))
+	header.Set("X-Tests-Errors", strconv.Itoa(r.Summary.Errors))
+	header.Set("X-Tests-Duration", fmt.Sprintf("%.3f", r.Summary.Duration))
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(part)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	report, err := ParseJUnit(strings.NewReader(sampleJUnit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := report.WriteTo(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	part, err := multipart.NewReader(&buf, w.Boundary()).NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"Content-Type":     MimeType,
+		"X-Tests-Total":    "4",
+		"X-Tests-Passed":   "1",
+		"X-Tests-Failed":   "1",
+		"X-Tests-Skipped":  "1",
+		"X-Tests-Errors":   "1",
+		"X-Tests-Duration": "1235.000",
+	} {
+		if got := part.Header.Get(key); got != want {
+			t.Errorf("Want %s %q, got %q", key, want, got)
+		}
+	}
+
+	decoded := new(Report)
+	if err := json.NewDecoder(part).Decode(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, report) {
+		t.Errorf("Want test report written as the part body")
+	}
+}
@@ -36,3 +36,11 @@ func WithContext(ctx context.Context) Option {
 		r.ctx = ctx
 	}
 }
+
+// WithServicePolicy returns an option configured with the policy
+// applied when a detached step exits unsuccessfully.
+func WithServicePolicy(policy ServicePolicy) Option {
+	return func(r *Runtime) {
+		r.policy = policy
+	}
+}
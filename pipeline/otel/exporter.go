@@ -0,0 +1,207 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Exporter exports spans to a trace collector.
+type Exporter interface {
+	Export(context.Context, []*Span) error
+}
+
+// ExportFunc type is an adapter to allow the use of ordinary
+// functions as an Exporter.
+type ExportFunc func(context.Context, []*Span) error
+
+// Export calls f(ctx, spans).
+func (f ExportFunc) Export(ctx context.Context, spans []*Span) error {
+	return f(ctx, spans)
+}
+
+// scope is the instrumentation scope reported with the spans.
+const scope = "github.com/cncd/pipeline"
+
+type httpExporter struct {
+	endpoint string
+	service  string
+	client   *http.Client
+}
+
+// NewHTTPExporter returns an exporter that sends spans to the collector
+// using the OTLP/HTTP protocol with JSON encoding. The spans are posted
+// to the /v1/traces path when the endpoint does not include a path.
+func NewHTTPExporter(endpoint, service string) Exporter {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if i := strings.Index(endpoint, "://"); i == -1 || !strings.Contains(endpoint[i+3:], "/") {
+		endpoint = endpoint + "/v1/traces"
+	}
+	return &httpExporter{
+		endpoint: endpoint,
+		service:  service,
+		client:   http.DefaultClient,
+	}
+}
+
+func (e *httpExporter) Export(ctx context.Context, spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(encode(e.service, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode > 299 {
+		return fmt.Errorf("otel: collector responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+//
+// OTLP/HTTP JSON encoding.
+//
+
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+	}
+)
+
+// span kind and status codes defined by the OTLP specification.
+const (
+	kindInternal    = 1
+	statusCodeOk    = 1
+	statusCodeError = 2
+)
+
+// helper function encodes the spans as an OTLP export request.
+func encode(service string, spans []*Span) *otlpRequest {
+	var list []otlpSpan
+	for _, span := range spans {
+		out := otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			ParentSpanID:      span.ParentID.String(),
+			Name:              span.Name,
+			Kind:              kindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        attributes(span.Attributes),
+			Status:            otlpStatus{Code: statusCodeOk},
+		}
+		if span.Error != "" {
+			out.Status = otlpStatus{Code: statusCodeError, Message: span.Error}
+		}
+		list = append(list, out)
+	}
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: attributes(map[string]interface{}{"service.name": service}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: scope},
+				Spans: list,
+			}},
+		}},
+	}
+}
+
+// helper function encodes the attributes, sorted by key.
+func attributes(attrs map[string]interface{}) []otlpAttribute {
+	var keys []string
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var list []otlpAttribute
+	for _, key := range keys {
+		var value otlpValue
+		switch v := attrs[key].(type) {
+		case bool:
+			value.BoolValue = &v
+		case int:
+			s := strconv.Itoa(v)
+			value.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		list = append(list, otlpAttribute{Key: key, Value: value})
+	}
+	return list
+}
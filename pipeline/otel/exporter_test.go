@@ -0,0 +1,75 @@
+package otel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPExporter(t *testing.T) {
+	var got otlpRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("Want spans posted to /v1/traces, got %s", r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Want json content type, got %s", ct)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer collector.Close()
+
+	trace, _ := ParseTraceID("4bf92f3577b34da6a3ce929d0e0e4736")
+	span := newSpan(trace, SpanID{}, "pipeline")
+	span.End = span.Start.Add(time.Second)
+	span.Attributes["pipeline.id"] = "1"
+	span.Error = "exit code 1"
+
+	exporter := NewHTTPExporter(collector.URL, "piped")
+	if err := exporter.Export(context.Background(), []*Span{span}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Want a single resource and scope, got %+v", got)
+	}
+	if attr := got.ResourceSpans[0].Resource.Attributes[0]; attr.Key != "service.name" || *attr.Value.StringValue != "piped" {
+		t.Errorf("Want service name attribute, got %+v", attr)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("Want one span, got %d", len(spans))
+	}
+	if spans[0].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Want hex encoded trace id, got %s", spans[0].TraceID)
+	}
+	if spans[0].ParentSpanID != "" {
+		t.Errorf("Want root span without parent, got %s", spans[0].ParentSpanID)
+	}
+	if spans[0].Status.Code != statusCodeError || spans[0].Status.Message != "exit code 1" {
+		t.Errorf("Want error status, got %+v", spans[0].Status)
+	}
+}
+
+func TestHTTPExporterError(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	exporter := NewHTTPExporter(collector.URL+"/", "piped")
+	if err := exporter.Export(context.Background(), []*Span{newSpan(NewTraceID(), SpanID{}, "pipeline")}); err == nil {
+		t.Errorf("Want error when the collector rejects the spans")
+	}
+}
+
+func TestParseTraceID(t *testing.T) {
+	for _, s := range []string{"", "xyz", "4bf92f35", "00000000000000000000000000000000"} {
+		if _, err := ParseTraceID(s); err == nil {
+			t.Errorf("Want error parsing trace id %q", s)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package otel
+
+// Option configures a tracer option.
+type Option func(*Tracer)
+
+// WithExporter configures the tracer with the exporter used to send
+// the spans to the collector when the pipeline ends.
+func WithExporter(exporter Exporter) Option {
+	return func(t *Tracer) {
+		t.exporter = exporter
+	}
+}
+
+// WithTraceID configures the tracer to record the spans in an existing
+// trace, so the agent and server spans are joined in a single trace.
+// Invalid identifiers are ignored and a new trace is started.
+func WithTraceID(id string) Option {
+	return func(t *Tracer) {
+		if trace, err := ParseTraceID(id); err == nil {
+			t.trace = trace
+		}
+	}
+}
+
+// WithPipeline configures the tracer with the pipeline identifier,
+// which is added as an attribute to the pipeline span.
+func WithPipeline(id string) Option {
+	return func(t *Tracer) {
+		t.pipeline = id
+	}
+}
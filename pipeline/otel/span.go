@@ -0,0 +1,98 @@
+package otel
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// TraceID defines a trace identifier.
+type TraceID [16]byte
+
+// SpanID defines a span identifier.
+type SpanID [8]byte
+
+// ErrInvalidID is returned when a trace or span identifier cannot be
+// parsed.
+var ErrInvalidID = errors.New("otel: invalid identifier")
+
+// NewTraceID returns a random trace identifier.
+func NewTraceID() (id TraceID) {
+	rand.Read(id[:])
+	return
+}
+
+// ParseTraceID parses a hex encoded trace identifier.
+func ParseTraceID(s string) (id TraceID, err error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(id) {
+		return id, ErrInvalidID
+	}
+	copy(id[:], b)
+	if id.IsZero() {
+		return id, ErrInvalidID
+	}
+	return id, nil
+}
+
+// String returns the hex encoded trace identifier.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsZero returns true if the trace identifier is not set.
+func (id TraceID) IsZero() bool {
+	return id == TraceID{}
+}
+
+// NewSpanID returns a random span identifier.
+func NewSpanID() (id SpanID) {
+	rand.Read(id[:])
+	return
+}
+
+// String returns the hex encoded span identifier, or an empty string
+// if the identifier is not set.
+func (id SpanID) String() string {
+	if id.IsZero() {
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// IsZero returns true if the span identifier is not set.
+func (id SpanID) IsZero() bool {
+	return id == SpanID{}
+}
+
+// Span defines a timed operation in the pipeline execution.
+type Span struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+
+	// Error is the error message of a failed operation.
+	Error string
+}
+
+// newSpan returns a new span started now.
+func newSpan(trace TraceID, parent SpanID, name string) *Span {
+	return &Span{
+		TraceID:    trace,
+		SpanID:     NewSpanID(),
+		ParentID:   parent,
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: map[string]interface{}{},
+	}
+}
+
+// Ended returns true if the span is ended.
+func (s *Span) Ended() bool {
+	return !s.End.IsZero()
+}
@@ -0,0 +1,218 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline"
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+// Tracer is a pipeline tracer that records the pipeline execution as
+// spans: one for the pipeline, one per stage and one per step, with a
+// child span for each phase of a step reported by the engine.
+type Tracer struct {
+	mu       sync.Mutex
+	exporter Exporter
+	trace    TraceID
+	pipeline string
+
+	root    *Span
+	stages  []*Span
+	steps   map[string]*Span
+	index   map[string]int
+	pending []int
+	spans   []*Span
+}
+
+// New returns a new tracer for the pipeline configuration.
+func New(spec *backend.Config, opts ...Option) *Tracer {
+	t := &Tracer{
+		steps:   map[string]*Span{},
+		index:   map[string]int{},
+		stages:  make([]*Span, len(spec.Stages)),
+		pending: make([]int, len(spec.Stages)),
+	}
+	for i, stage := range spec.Stages {
+		for _, proc := range stage.Steps {
+			t.index[proc.Name] = i
+		}
+		t.pending[i] = len(stage.Steps)
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.trace.IsZero() {
+		t.trace = NewTraceID()
+	}
+
+	t.root = newSpan(t.trace, SpanID{}, "pipeline")
+	if t.pipeline != "" {
+		t.root.Attributes["pipeline.id"] = t.pipeline
+	}
+
+	// stage spans start when the first step of the stage starts.
+	for i, stage := range spec.Stages {
+		span := newSpan(t.trace, t.root.SpanID, "stage")
+		span.Start = time.Time{}
+		span.Attributes["stage.name"] = stage.Name
+		span.Attributes["stage.index"] = i
+		t.stages[i] = span
+	}
+	return t
+}
+
+// TraceID returns the trace identifier.
+func (t *Tracer) TraceID() string {
+	return t.trace.String()
+}
+
+// Trace records the step state. It implements the pipeline.Tracer
+// interface and never skips a step.
+func (t *Tracer) Trace(state *pipeline.State) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case state.Status.Done():
+		t.endStep(state)
+	case state.Status.State == pipeline.StateRunning:
+		t.startStep(state.Pipeline.Step)
+	}
+	return nil
+}
+
+// Phase records a phase of the step, such as the image pull, the
+// container create or the wait for the container to exit, as a child
+// span of the step. Its signature matches the docker engine observer.
+func (t *Tracer) Phase(proc *backend.Step, phase string, started time.Time, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parent, ok := t.steps[proc.Name]
+	if !ok {
+		return
+	}
+	span := newSpan(t.trace, parent.SpanID, phase)
+	span.Start = started
+	span.Attributes["step.image"] = proc.Image
+	if err != nil {
+		span.Error = err.Error()
+	}
+	t.end(span)
+}
+
+// End ends the pipeline span, and any span still open, and exports the
+// spans to the collector.
+func (t *Tracer) End(ctx context.Context, err error) error {
+	t.mu.Lock()
+	for _, span := range t.steps {
+		if !span.Ended() {
+			t.end(span)
+		}
+	}
+	for _, span := range t.stages {
+		if span != nil && !span.Ended() {
+			t.end(span)
+		}
+	}
+	if err != nil {
+		t.root.Error = err.Error()
+	}
+	t.end(t.root)
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+
+	if t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Export(ctx, spans)
+}
+
+//
+// helper functions. The caller must hold the lock.
+//
+
+// stage returns the span of the stage the step belongs to, starting the
+// span if the step is the first step of the stage.
+func (t *Tracer) stage(proc *backend.Step) *Span {
+	span := t.stages[t.index[proc.Name]]
+	if span.Start.IsZero() {
+		span.Start = time.Now()
+	}
+	return span
+}
+
+func (t *Tracer) startStep(proc *backend.Step) *Span {
+	if span, ok := t.steps[proc.Name]; ok {
+		return span
+	}
+	span := newSpan(t.trace, t.stage(proc).SpanID, "step")
+	span.Attributes["step.name"] = proc.Name
+	span.Attributes["step.alias"] = proc.Alias
+	span.Attributes["step.image"] = proc.Image
+	span.Attributes["step.detached"] = proc.Detached
+	t.steps[proc.Name] = span
+	return span
+}
+
+func (t *Tracer) endStep(state *pipeline.State) {
+	proc := state.Pipeline.Step
+	span := t.startStep(proc)
+	if span.Ended() {
+		return
+	}
+
+	status := state.Status
+	span.Attributes["step.state"] = string(status.State)
+	span.Attributes["step.exit_code"] = status.ExitCode
+	span.Attributes["step.oom_killed"] = status.State == pipeline.StateOOM
+	if status.Reason != "" {
+		span.Attributes["step.reason"] = status.Reason
+	}
+	if usage := state.Process.Usage; usage != nil {
+		span.Attributes["step.memory_peak"] = int64(usage.MemoryPeak)
+		span.Attributes["step.cpu_seconds"] = usage.CPUSeconds
+	}
+	if failed(status) {
+		span.Error = fmt.Sprintf("step %s: %s", status.State, errorReason(status))
+	}
+	t.end(span)
+
+	i := t.index[proc.Name]
+	stage := t.stage(proc)
+	if span.Error != "" && stage.Error == "" {
+		stage.Error = span.Error
+	}
+	if t.pending[i]--; t.pending[i] == 0 {
+		t.end(stage)
+	}
+}
+
+func (t *Tracer) end(span *Span) {
+	if span.Start.IsZero() {
+		span.Start = time.Now()
+	}
+	span.End = time.Now()
+	t.spans = append(t.spans, span)
+}
+
+// helper function returns true if the step failed the pipeline.
+func failed(status pipeline.StepStatus) bool {
+	switch status.State {
+	case pipeline.StateFailure, pipeline.StateOOM, pipeline.StateKilled, pipeline.StateTimeout:
+		return status.Reason != pipeline.ReasonAllowed
+	}
+	return false
+}
+
+// helper function returns the reason the step failed.
+func errorReason(status pipeline.StepStatus) string {
+	if status.Reason != "" {
+		return status.Reason
+	}
+	return fmt.Sprintf("exit code %d", status.ExitCode)
+}
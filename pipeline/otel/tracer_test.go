@@ -0,0 +1,101 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline"
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+func TestTracer(t *testing.T) {
+	build := &backend.Step{Name: "pipeline_step_0", Alias: "build", Image: "golang"}
+	deploy := &backend.Step{Name: "pipeline_step_1", Alias: "deploy", Image: "alpine"}
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{Name: "pipeline_stage_0", Steps: []*backend.Step{build}},
+			{Name: "pipeline_stage_1", Steps: []*backend.Step{deploy}},
+		},
+	}
+
+	var spans []*Span
+	exporter := ExportFunc(func(_ context.Context, s []*Span) error {
+		spans = s
+		return nil
+	})
+
+	tracer := New(spec,
+		WithExporter(exporter),
+		WithTraceID("4bf92f3577b34da6a3ce929d0e0e4736"),
+		WithPipeline("1"),
+	)
+	tracer.Trace(traceState(build, pipeline.StateRunning, 0, ""))
+	tracer.Phase(build, "pull", time.Now(), nil)
+	tracer.Phase(build, "create", time.Now(), nil)
+	tracer.Trace(traceState(build, pipeline.StateOOM, 137, ""))
+	tracer.Trace(traceState(deploy, pipeline.StateSkipped, 0, pipeline.ReasonFailure))
+
+	if err := tracer.End(context.Background(), errors.New("oom")); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := map[string][]*Span{}
+	for _, span := range spans {
+		if span.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Want spans recorded in the propagated trace")
+		}
+		if !span.Ended() {
+			t.Errorf("Want span %s ended", span.Name)
+		}
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+	if got := len(byName["pipeline"]); got != 1 {
+		t.Fatalf("Want one pipeline span, got %d", got)
+	}
+	if got := len(byName["stage"]); got != 2 {
+		t.Errorf("Want one span per stage, got %d", got)
+	}
+	if got := len(byName["step"]); got != 2 {
+		t.Fatalf("Want one span per step, got %d", got)
+	}
+	if got := len(byName["pull"]) + len(byName["create"]); got != 2 {
+		t.Errorf("Want child spans for the pull and create phases, got %d", got)
+	}
+
+	step := byName["step"][0]
+	if step.Attributes["step.image"] != "golang" || step.Attributes["step.oom_killed"] != true || step.Attributes["step.exit_code"] != 137 {
+		t.Errorf("Want step image, exit code and oom attributes, got %v", step.Attributes)
+	}
+	if step.Error == "" {
+		t.Errorf("Want oom killed step span with error status")
+	}
+	if pull := byName["pull"][0]; pull.ParentID != step.SpanID {
+		t.Errorf("Want pull span child of the step span")
+	}
+	if step.ParentID != byName["stage"][0].SpanID {
+		t.Errorf("Want step span child of the stage span")
+	}
+	if byName["pipeline"][0].Error != "oom" {
+		t.Errorf("Want pipeline span with error status")
+	}
+}
+
+func traceState(proc *backend.Step, state pipeline.StepState, code int, reason string) *pipeline.State {
+	s := new(pipeline.State)
+	s.Pipeline.Step = proc
+	s.Process = &backend.State{
+		Exited:    state != pipeline.StateRunning,
+		ExitCode:  code,
+		OOMKilled: state == pipeline.StateOOM,
+	}
+	s.Status = pipeline.StepStatus{
+		Name:     proc.Name,
+		Alias:    proc.Alias,
+		State:    state,
+		Reason:   reason,
+		ExitCode: code,
+	}
+	return s
+}
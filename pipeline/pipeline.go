@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -25,9 +26,17 @@ type (
 
 		// Current process state.
 		Process *backend.State
+
+		// Current step status.
+		Status StepStatus
 	}
 )
 
+// defaultStopGracePeriod is the time a step is given to exit after
+// receiving its stop signal, when the step does not define a grace
+// period.
+const defaultStopGracePeriod = 10 * time.Second
+
 // Runtime is a configuration runtime.
 type Runtime struct {
 	err     error
@@ -35,6 +44,16 @@ type Runtime struct {
 	engine  backend.Engine
 	started int64
 
+	mu     sync.Mutex
+	steps  map[*backend.Step]*StepStatus
+	ending bool
+
+	policy   ServicePolicy
+	svcerr   error
+	monitors sync.WaitGroup
+	mctx     context.Context
+	stage    context.CancelFunc // cancels the running stage
+
 	ctx    context.Context
 	tracer Tracer
 	logger Logger
@@ -46,6 +65,7 @@ func New(spec *backend.Config, opts ...Option) *Runtime {
 	r := new(Runtime)
 	r.spec = spec
 	r.ctx = context.Background()
+	r.steps = map[*backend.Step]*StepStatus{}
 	for _, opts := range opts {
 		opts(r)
 	}
@@ -54,7 +74,18 @@ func New(spec *backend.Config, opts ...Option) *Runtime {
 
 // Run starts the runtime and waits for it to complete.
 func (r *Runtime) Run() error {
+	var cancel context.CancelFunc
+	r.mctx, cancel = context.WithCancel(noContext)
+
 	defer func() {
+		r.mu.Lock()
+		r.ending = true
+		r.mu.Unlock()
+
+		r.stopAll()
+		cancel()
+		r.monitors.Wait()
+		r.finalize()
 		r.engine.Destroy(r.ctx, r.spec)
 	}()
 
@@ -67,33 +98,63 @@ func (r *Runtime) Run() error {
 		select {
 		case <-r.ctx.Done():
 			return ErrCancel
-		case err := <-r.execAll(stage.Steps):
+		case err := <-r.execAll(stage):
 			if err != nil {
-				r.err = err
+				r.setErr(err)
+			}
+			if err := r.serviceErr(); err != nil && r.getErr() == nil {
+				r.setErr(err)
 			}
 		}
 	}
 
+	return r.getErr()
+}
+
+// getErr returns the pipeline error.
+func (r *Runtime) getErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.err
 }
 
+// setErr sets the pipeline error.
+func (r *Runtime) setErr(err error) {
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+}
+
 //
 //
 //
 
-func (r *Runtime) execAll(procs []*backend.Step) <-chan error {
+func (r *Runtime) execAll(stage *backend.Stage) <-chan error {
 	var g errgroup.Group
-	done := make(chan error)
+	done := make(chan error, 1)
 
-	for _, proc := range procs {
+	// when fail fast is enabled the remaining steps in the stage are
+	// killed as soon as one of the steps fails. The steps are also
+	// killed when a service fails under the fail policy.
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.mu.Lock()
+	r.stage = cancel
+	r.mu.Unlock()
+
+	for _, proc := range stage.Steps {
 		proc := proc
 		g.Go(func() error {
-			return r.exec(proc)
+			err := r.exec(ctx, proc)
+			if err != nil && stage.FailFast {
+				cancel()
+			}
+			return err
 		})
 	}
 
 	go func() {
 		done <- g.Wait()
+		cancel()
 		close(done)
 	}()
 	return done
@@ -103,34 +164,49 @@ func (r *Runtime) execAll(procs []*backend.Step) <-chan error {
 //
 //
 
-func (r *Runtime) exec(proc *backend.Step) error {
+func (r *Runtime) exec(ctx context.Context, proc *backend.Step) error {
+	err := r.getErr()
 	switch {
-	case r.err != nil && proc.OnFailure == false:
+	case r.ctx.Err() != nil:
+		return r.skip(proc, cancelReason(r.ctx))
+	case ctx.Err() != nil:
+		return r.skip(proc, r.stageReason())
+	case err != nil && proc.OnFailure == false:
+		return r.skip(proc, ReasonFailure)
+	case err == nil && proc.OnSuccess == false:
+		return r.skip(proc, ReasonSuccess)
+	}
+
+	if !r.transition(proc, StateRunning, 0, "") {
 		return nil
-	case r.err == nil && proc.OnSuccess == false:
+	}
+	if err := r.trace(proc, new(backend.State)); err == ErrSkip {
+		r.transition(proc, StateSkipped, 0, ReasonTracer)
 		return nil
+	} else if err != nil {
+		r.transition(proc, StateFailure, 0, err.Error())
+		return err
 	}
 
-	if r.tracer != nil {
-		state := new(State)
-		state.Pipeline.Time = r.started
-		state.Pipeline.Error = r.err
-		state.Pipeline.Step = proc
-		state.Process = new(backend.State) // empty
-		if err := r.tracer.Trace(state); err == ErrSkip {
+	if err := r.engine.Exec(ctx, proc); err != nil {
+		if r.failedFast(ctx, proc) {
 			return nil
-		} else if err != nil {
-			return err
 		}
-	}
-
-	if err := r.engine.Exec(r.ctx, proc); err != nil {
+		r.transition(proc, StateFailure, 0, err.Error())
 		return err
 	}
 
 	if r.logger != nil {
-		rc, err := r.engine.Tail(r.ctx, proc)
+		// the logs of detached steps are streamed until the step
+		// exits, even when the pipeline is cancelled, so the output
+		// of a crashed service is never lost.
+		tailctx := ctx
+		if proc.Detached {
+			tailctx = noContext
+		}
+		rc, err := r.engine.Tail(tailctx, proc)
 		if err != nil {
+			r.transition(proc, StateFailure, 0, err.Error())
 			return err
 		}
 
@@ -141,35 +217,223 @@ func (r *Runtime) exec(proc *backend.Step) error {
 	}
 
 	if proc.Detached {
+		r.monitor(proc)
 		return nil
 	}
 
-	wait, err := r.engine.Wait(r.ctx, proc)
+	sampling := r.sample(ctx, proc)
+	wait, err := r.engine.Wait(ctx, proc)
+	usage := sampling()
+	if err != nil && r.failedFast(ctx, proc) {
+		return nil
+	}
 	if err != nil {
+		// steps interrupted by the pipeline being cancelled are
+		// moved to their final state when the pipeline ends.
+		if r.ctx.Err() == nil {
+			r.transition(proc, StateFailure, 0, err.Error())
+		}
 		return err
 	}
+	if r.ctx.Err() != nil {
+		return ErrCancel
+	}
+	wait.Usage = usage
+	state, reason := exitState(wait), ""
+	if state != StateSuccess && proc.AllowFailure {
+		reason = ReasonAllowed
+	}
+	r.transition(proc, state, wait.ExitCode, reason)
 
-	if r.tracer != nil {
-		state := new(State)
-		state.Pipeline.Time = r.started
-		state.Pipeline.Error = r.err
-		state.Pipeline.Step = proc
-		state.Process = wait
-		if err := r.tracer.Trace(state); err != nil {
-			return err
-		}
+	if err := r.trace(proc, wait); err != nil {
+		return err
 	}
 
-	if wait.OOMKilled {
+	// steps that are allowed to fail do not fail the pipeline.
+	if proc.AllowFailure {
+		return nil
+	}
+	return exitError(proc, wait)
+}
+
+// failedFast returns true if the step was interrupted because another
+// step in the stage or a service failed, in which case the step is stopped and moved
+// to the killed state.
+func (r *Runtime) failedFast(ctx context.Context, proc *backend.Step) bool {
+	if ctx.Err() == nil || r.ctx.Err() != nil {
+		return false
+	}
+	r.stop(proc)
+	if r.transition(proc, StateKilled, 137, r.stageReason()) {
+		r.trace(proc, &backend.State{Exited: true, ExitCode: 137})
+	}
+	return true
+}
+
+// stageReason returns the reason the steps of the stage are interrupted.
+func (r *Runtime) stageReason() string {
+	if r.serviceErr() != nil {
+		return ReasonService
+	}
+	return ReasonFailFast
+}
+
+// skip moves the step to the skipped state and reports the step
+// through the tracer.
+func (r *Runtime) skip(proc *backend.Step, reason string) error {
+	if r.transition(proc, StateSkipped, 0, reason) {
+		r.trace(proc, &backend.State{Exited: true})
+	}
+	return nil
+}
+
+// trace reports the step state through the tracer.
+func (r *Runtime) trace(proc *backend.Step, process *backend.State) error {
+	if r.tracer == nil {
+		return nil
+	}
+	state := new(State)
+	state.Pipeline.Time = r.started
+	state.Pipeline.Error = r.getErr()
+	state.Pipeline.Step = proc
+	state.Process = process
+	state.Status = r.status(proc)
+	return r.tracer.Trace(state)
+}
+
+// sample samples the step resource usage in the background, if the
+// engine supports it. The returned function stops sampling and returns
+// the resource usage.
+func (r *Runtime) sample(ctx context.Context, proc *backend.Step) func() *backend.Usage {
+	sampler, ok := r.engine.(backend.Sampler)
+	if !ok {
+		return func() *backend.Usage { return nil }
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan *backend.Usage, 1)
+	go func() {
+		usage, _ := sampler.Sample(ctx, proc)
+		done <- usage
+	}()
+	return func() *backend.Usage {
+		cancel()
+		return <-done
+	}
+}
+
+// exitError returns an error if the step exited unsuccessfully.
+func exitError(proc *backend.Step, state *backend.State) error {
+	if state.OOMKilled {
 		return &OomError{
 			Name: proc.Name,
-			Code: wait.ExitCode,
+			Code: state.ExitCode,
 		}
-	} else if wait.ExitCode != 0 {
+	} else if state.ExitCode != 0 {
 		return &ExitError{
 			Name: proc.Name,
-			Code: wait.ExitCode,
+			Code: state.ExitCode,
 		}
 	}
 	return nil
 }
+
+// cancelReason returns the reason steps are skipped or killed when
+// the context is done.
+func cancelReason(ctx context.Context) string {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ReasonTimeout
+	}
+	return ReasonCancelled
+}
+
+//
+//
+//
+
+// finalize moves the steps that are still pending or running to their
+// terminal state when the pipeline ends. Steps interrupted by the
+// pipeline being cancelled, and all detached steps, are reported
+// through the tracer. Detached steps that are still running when the
+// pipeline completes are considered successful.
+func (r *Runtime) finalize() {
+	cancelled := r.ctx.Err() != nil
+	for _, stage := range r.spec.Stages {
+		for _, proc := range stage.Steps {
+			status := r.status(proc)
+			switch {
+			case status.State == StatePending && cancelled:
+				r.skip(proc, cancelReason(r.ctx))
+			case status.State == StatePending:
+				// the pipeline ended early, for example because
+				// the environment could not be setup.
+				r.skip(proc, ReasonFailure)
+			case status.State == StateRunning && cancelled:
+				state := StateKilled
+				if r.ctx.Err() == context.DeadlineExceeded {
+					state = StateTimeout
+				}
+				r.transition(proc, state, 137, cancelReason(r.ctx))
+				r.trace(proc, &backend.State{Exited: true, ExitCode: 137})
+			case status.State == StateRunning:
+				r.transition(proc, StateSuccess, 0, "")
+				r.trace(proc, &backend.State{Exited: true})
+			case proc.Detached && status.Started != 0:
+				r.trace(proc, &backend.State{
+					Exited:    true,
+					ExitCode:  status.ExitCode,
+					OOMKilled: status.State == StateOOM,
+				})
+			}
+		}
+	}
+}
+
+// stopAll gracefully stops the steps that are still running, including
+// detached steps, before the pipeline environment is destroyed.
+func (r *Runtime) stopAll() {
+	var procs []*backend.Step
+	for _, stage := range r.spec.Stages {
+		for _, proc := range stage.Steps {
+			if r.status(proc).State == StateRunning {
+				procs = append(procs, proc)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, proc := range procs {
+		wg.Add(1)
+		go func(proc *backend.Step) {
+			r.stop(proc)
+			wg.Done()
+		}(proc)
+	}
+	wg.Wait()
+}
+
+// stop sends the stop signal to the step, which is the engine default
+// signal if the step does not define one, and waits for the step to exit
+// or for the grace period to expire before killing the step. Steps are
+// only killed if the engine supports it, otherwise they are removed
+// when the pipeline environment is destroyed.
+func (r *Runtime) stop(proc *backend.Step) {
+	grace := proc.StopGrace
+	if grace == 0 {
+		grace = defaultStopGracePeriod
+	}
+	if r.engine.Kill(noContext, proc) == nil {
+		ctx, cancel := context.WithTimeout(noContext, grace)
+		_, err := r.engine.Wait(ctx, proc)
+		cancel()
+		if err == nil {
+			return
+		}
+	}
+	if killer, ok := r.engine.(backend.ForceKiller); ok {
+		killer.ForceKill(noContext, proc)
+	}
+}
+
+// noContext is an empty context used when the runtime context may
+// already be cancelled.
+var noContext = context.Background()
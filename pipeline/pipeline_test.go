@@ -0,0 +1,369 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/multipart"
+)
+
+func TestRuntimeGracefulStop(t *testing.T) {
+	engine := newMockEngine()
+	engine.procs["build"] = &mockProc{duration: time.Hour}
+
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{Steps: []*backend.Step{{Name: "build", StopSignal: "SIGINT", OnSuccess: true}}},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := New(spec, WithEngine(engine), WithContext(ctx)).Run()
+	if err != ErrCancel {
+		t.Errorf("Want cancel error, got %v", err)
+	}
+	if got, want := engine.killed["build"], []string{"SIGINT"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want signals %v, got %v", want, got)
+	}
+	if !engine.destroyed {
+		t.Errorf("Want pipeline environment destroyed")
+	}
+}
+
+func TestRuntimeDefaultStopSignal(t *testing.T) {
+	engine := newMockEngine()
+	engine.procs["build"] = &mockProc{duration: time.Hour}
+
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{Steps: []*backend.Step{{Name: "build", OnSuccess: true}}},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	New(spec, WithEngine(engine), WithContext(ctx)).Run()
+	if got, want := engine.killed["build"], []string{""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want the engine default stop signal without a stop signal, got %v", got)
+	}
+}
+
+func TestRuntimeForceKill(t *testing.T) {
+	engine := newMockEngine()
+	engine.procs["build"] = &mockProc{duration: time.Hour, ignoreStop: true}
+
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{Steps: []*backend.Step{{Name: "build", StopSignal: "SIGINT", StopGrace: 10 * time.Millisecond, OnSuccess: true}}},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	New(spec, WithEngine(engine), WithContext(ctx)).Run()
+	if got, want := engine.killed["build"], []string{"SIGINT", "SIGKILL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want step killed when the grace period expires, got %v", got)
+	}
+}
+
+func TestRuntimeServiceFail(t *testing.T) {
+	engine := newMockEngine()
+	engine.procs["database"] = &mockProc{code: 1, duration: 10 * time.Millisecond}
+	engine.procs["build"] = &mockProc{duration: time.Hour}
+
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{Steps: []*backend.Step{{Name: "database", Detached: true, OnSuccess: true}}},
+			{Steps: []*backend.Step{{Name: "build", OnSuccess: true}}},
+			{Steps: []*backend.Step{{Name: "deploy", OnSuccess: true}}},
+		},
+	}
+
+	var (
+		mu     sync.Mutex
+		traced []*State
+	)
+	tracer := TraceFunc(func(state *State) error {
+		mu.Lock()
+		traced = append(traced, state)
+		mu.Unlock()
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r := New(spec,
+		WithEngine(engine),
+		WithTracer(tracer),
+		WithContext(ctx),
+		WithServicePolicy(ServiceFail),
+	)
+	err := r.Run()
+
+	if xerr, ok := err.(*ExitError); !ok || xerr.Name != "database" {
+		t.Errorf("Want service exit error, got %v", err)
+	}
+	if got := r.Status()[1]; got.State != StateKilled || got.Reason != ReasonService {
+		t.Errorf("Want running step killed when the service fails, got %s: %s", got.State, got.Reason)
+	}
+	for _, state := range traced {
+		if state.Pipeline.Step.Name == "deploy" && state.Status.State != StateSkipped {
+			t.Errorf("Want steps skipped after service failure")
+		}
+	}
+	last := traced[len(traced)-1]
+	if last.Pipeline.Step.Name != "database" || last.Process.ExitCode != 1 {
+		t.Errorf("Want service exit state traced when the pipeline ends")
+	}
+}
+
+func TestRuntimeServiceWarn(t *testing.T) {
+	engine := newMockEngine()
+	engine.procs["database"] = &mockProc{code: 1, duration: 10 * time.Millisecond}
+	engine.procs["build"] = &mockProc{duration: 50 * time.Millisecond}
+
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{Steps: []*backend.Step{{Name: "database", Detached: true, OnSuccess: true}}},
+			{Steps: []*backend.Step{{Name: "build", OnSuccess: true}}},
+		},
+	}
+
+	err := New(spec, WithEngine(engine)).Run()
+	if err != nil {
+		t.Errorf("Want service failure ignored, got %v", err)
+	}
+}
+
+func TestRuntimeFailFast(t *testing.T) {
+	engine := newMockEngine()
+	engine.procs["test_unit"] = &mockProc{code: 1, duration: 10 * time.Millisecond}
+	engine.procs["test_integration"] = &mockProc{duration: time.Hour}
+
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{
+				FailFast: true,
+				Steps: []*backend.Step{
+					{Name: "test_unit", OnSuccess: true},
+					{Name: "test_integration", OnSuccess: true},
+				},
+			},
+		},
+	}
+
+	r := New(spec, WithEngine(engine))
+	err := r.Run()
+	if xerr, ok := err.(*ExitError); !ok || xerr.Name != "test_unit" {
+		t.Errorf("Want exit error from the failed step, got %v", err)
+	}
+
+	status := r.Status()
+	if got := status[0].State; got != StateFailure {
+		t.Errorf("Want failed step state failure, got %s", got)
+	}
+	if got := status[1]; got.State != StateKilled || got.Reason != ReasonFailFast {
+		t.Errorf("Want sibling step killed, got %s: %s", got.State, got.Reason)
+	}
+}
+
+func TestRuntimeAllowFailure(t *testing.T) {
+	engine := newMockEngine()
+	engine.procs["lint"] = &mockProc{code: 1}
+
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{Steps: []*backend.Step{{Name: "lint", OnSuccess: true, AllowFailure: true}}},
+			{Steps: []*backend.Step{{Name: "build", OnSuccess: true}}},
+		},
+	}
+
+	r := New(spec, WithEngine(engine))
+	if err := r.Run(); err != nil {
+		t.Errorf("Want allowed failure to keep the pipeline green, got %v", err)
+	}
+
+	status := r.Status()
+	if got := status[0]; got.State != StateFailure || got.Reason != ReasonAllowed || got.ExitCode != 1 {
+		t.Errorf("Want allowed failure recorded, got %s: %s", got.State, got.Reason)
+	}
+	if got := status[1].State; got != StateSuccess {
+		t.Errorf("Want subsequent step to run, got %s", got)
+	}
+}
+
+func TestRuntimeUsage(t *testing.T) {
+	engine := &mockSampler{newMockEngine()}
+	engine.procs["build"] = &mockProc{duration: 10 * time.Millisecond}
+
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{Steps: []*backend.Step{{Name: "build", OnSuccess: true}}},
+		},
+	}
+
+	var usage *backend.Usage
+	tracer := TraceFunc(func(state *State) error {
+		if state.Process.Exited {
+			usage = state.Process.Usage
+		}
+		return nil
+	})
+
+	New(spec, WithEngine(engine), WithTracer(tracer)).Run()
+	if usage == nil || usage.MemoryPeak != 1024 {
+		t.Errorf("Want sampled usage traced when the step exits, got %v", usage)
+	}
+}
+
+func TestRuntimeTailError(t *testing.T) {
+	engine := newMockEngine()
+	engine.procs["build"] = &mockProc{tailErr: errors.New("tail error")}
+
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{Steps: []*backend.Step{{Name: "build", OnSuccess: true}}},
+		},
+	}
+	logger := LogFunc(func(*backend.Step, multipart.Reader) error {
+		return nil
+	})
+
+	r := New(spec, WithEngine(engine), WithLogger(logger))
+	if err := r.Run(); err == nil || err.Error() != "tail error" {
+		t.Errorf("Want tail error, got %v", err)
+	}
+	if got := r.Status()[0]; got.State != StateFailure || got.Reason != "tail error" {
+		t.Errorf("Want step failed when the logs cannot be attached, got %s: %s", got.State, got.Reason)
+	}
+}
+
+//
+// mock engine used to test the runtime.
+//
+
+type mockProc struct {
+	code     int
+	oom      bool
+	duration time.Duration
+	tailErr  error
+	stopped  chan struct{}
+
+	// ignoreStop is true if the step does not exit when it receives
+	// the stop signal.
+	ignoreStop bool
+}
+
+// stop signals the step exited. The caller must hold the engine lock.
+func (p *mockProc) stop() {
+	select {
+	case <-p.stopped:
+	default:
+		close(p.stopped)
+	}
+}
+
+type mockEngine struct {
+	sync.Mutex
+	procs     map[string]*mockProc
+	killed    map[string][]string
+	destroyed bool
+}
+
+func newMockEngine() *mockEngine {
+	return &mockEngine{
+		procs:  map[string]*mockProc{},
+		killed: map[string][]string{},
+	}
+}
+
+func (e *mockEngine) proc(name string) *mockProc {
+	e.Lock()
+	defer e.Unlock()
+	proc, ok := e.procs[name]
+	if !ok {
+		proc = new(mockProc)
+		e.procs[name] = proc
+	}
+	if proc.stopped == nil {
+		proc.stopped = make(chan struct{})
+	}
+	return proc
+}
+
+func (e *mockEngine) Setup(context.Context, *backend.Config) error {
+	return nil
+}
+
+func (e *mockEngine) Exec(_ context.Context, step *backend.Step) error {
+	e.proc(step.Name)
+	return nil
+}
+
+func (e *mockEngine) Kill(_ context.Context, step *backend.Step) error {
+	proc := e.proc(step.Name)
+	e.Lock()
+	defer e.Unlock()
+	if !proc.ignoreStop {
+		proc.stop()
+	}
+	e.killed[step.Name] = append(e.killed[step.Name], step.StopSignal)
+	return nil
+}
+
+func (e *mockEngine) ForceKill(_ context.Context, step *backend.Step) error {
+	proc := e.proc(step.Name)
+	e.Lock()
+	defer e.Unlock()
+	proc.stop()
+	e.killed[step.Name] = append(e.killed[step.Name], "SIGKILL")
+	return nil
+}
+
+func (e *mockEngine) Wait(ctx context.Context, step *backend.Step) (*backend.State, error) {
+	proc := e.proc(step.Name)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-proc.stopped:
+		return &backend.State{Exited: true, ExitCode: 143}, nil
+	case <-time.After(proc.duration):
+		return &backend.State{Exited: true, ExitCode: proc.code, OOMKilled: proc.oom}, nil
+	}
+}
+
+func (e *mockEngine) Tail(_ context.Context, step *backend.Step) (io.ReadCloser, error) {
+	if err := e.proc(step.Name).tailErr; err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader("")), nil
+}
+
+type mockSampler struct {
+	*mockEngine
+}
+
+func (e *mockSampler) Sample(ctx context.Context, _ *backend.Step) (*backend.Usage, error) {
+	<-ctx.Done()
+	return &backend.Usage{MemoryPeak: 1024}, nil
+}
+
+func (e *mockEngine) Destroy(context.Context, *backend.Config) error {
+	e.Lock()
+	e.destroyed = true
+	e.Unlock()
+	return nil
+}
@@ -0,0 +1,178 @@
+package rpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Errors returned when the agent cannot be authenticated.
+var (
+	ErrUnauthorized  = errors.New("rpc: unauthorized")
+	ErrAgentMismatch = errors.New("rpc: token and certificate identify different agents")
+)
+
+// Agent defines the identity of an authenticated agent.
+type Agent struct {
+	ID string `json:"id"`
+}
+
+type agentKey struct{}
+
+// WithAgent returns a copy of the context that carries the agent
+// identity.
+func WithAgent(ctx context.Context, agent *Agent) context.Context {
+	return context.WithValue(ctx, agentKey{}, agent)
+}
+
+// withVerifiedAgent returns a copy of the context that carries the
+// agent identity. When the agent presents both a token and a client
+// certificate, the identities must match. Tokens that do not identify
+// the agent, such as a shared secret, match any identity.
+func withVerifiedAgent(ctx context.Context, agent *Agent) (context.Context, error) {
+	if prev, ok := AgentFromContext(ctx); ok && prev.ID != "" {
+		if agent.ID != "" && agent.ID != prev.ID {
+			return nil, ErrAgentMismatch
+		}
+		if agent.ID == "" {
+			return ctx, nil
+		}
+	}
+	return WithAgent(ctx, agent), nil
+}
+
+// AgentFromContext returns the identity of the agent that made the
+// request, if the server authenticates agents.
+func AgentFromContext(ctx context.Context) (*Agent, bool) {
+	agent, ok := ctx.Value(agentKey{}).(*Agent)
+	return agent, ok
+}
+
+// Authenticator authenticates agents using the token sent with each
+// request.
+type Authenticator interface {
+	Authenticate(token string) (*Agent, error)
+}
+
+// AuthenticatorFunc type is an adapter to allow the use of ordinary
+// functions as an Authenticator.
+type AuthenticatorFunc func(token string) (*Agent, error)
+
+// Authenticate calls f(token).
+func (f AuthenticatorFunc) Authenticate(token string) (*Agent, error) {
+	return f(token)
+}
+
+// NewSecretAuthenticator returns an authenticator that accepts agents
+// sending the shared secret. All agents share the given identity.
+func NewSecretAuthenticator(id, secret string) Authenticator {
+	return AuthenticatorFunc(func(token string) (*Agent, error) {
+		if secret == "" || !equal(token, secret) {
+			return nil, ErrUnauthorized
+		}
+		return &Agent{ID: id}, nil
+	})
+}
+
+// NewTokenAuthenticator returns an authenticator that accepts agents
+// sending one of the tokens, which are mapped to the agent identity.
+func NewTokenAuthenticator(tokens map[string]string) Authenticator {
+	return AuthenticatorFunc(func(token string) (*Agent, error) {
+		for id, secret := range tokens {
+			if secret != "" && equal(token, secret) {
+				return &Agent{ID: id}, nil
+			}
+		}
+		return nil, ErrUnauthorized
+	})
+}
+
+// NewJWTAuthenticator returns an authenticator that accepts agents
+// sending a JSON Web Token signed with HS256 using the secret. The
+// token must include an expiration time, and the subject claim is used
+// as the agent identity.
+func NewJWTAuthenticator(secret []byte) Authenticator {
+	return AuthenticatorFunc(func(token string) (*Agent, error) {
+		claims, err := verifyJWT(token, secret, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return &Agent{ID: claims.Subject}, nil
+	})
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Subject   string  `json:"sub"`
+	Expires   float64 `json:"exp"`
+	NotBefore float64 `json:"nbf"`
+}
+
+// helper function verifies the token signature and expiration time,
+// and returns the token claims.
+func verifyJWT(token string, secret []byte, now time.Time) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || len(secret) == 0 {
+		return nil, ErrUnauthorized
+	}
+
+	header := new(jwtHeader)
+	if err := decodeSegment(parts[0], header); err != nil || header.Alg != "HS256" {
+		return nil, ErrUnauthorized
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, ErrUnauthorized
+	}
+
+	claims := new(jwtClaims)
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, ErrUnauthorized
+	}
+	unix := float64(now.Unix())
+	switch {
+	case claims.Expires == 0 || unix >= claims.Expires:
+		return nil, ErrUnauthorized
+	case claims.NotBefore != 0 && unix < claims.NotBefore:
+		return nil, ErrUnauthorized
+	}
+	return claims, nil
+}
+
+// helper function decodes the base64 url encoded json segment.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// helper function compares the strings in constant time.
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// Bearer returns the token from a bearer authorization header value, or
+// an empty string if the value is not a bearer token.
+func Bearer(value string) string {
+	if len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+		return value[7:]
+	}
+	return ""
+}
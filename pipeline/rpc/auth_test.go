@@ -0,0 +1,206 @@
+package rpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	netcontext "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestSecretAuthenticator(t *testing.T) {
+	auth := NewSecretAuthenticator("shared", "correct-horse")
+	if agent, err := auth.Authenticate("correct-horse"); err != nil || agent.ID != "shared" {
+		t.Errorf("Want agent authenticated with the shared secret, got %v", err)
+	}
+	if _, err := auth.Authenticate("battery-staple"); err != ErrUnauthorized {
+		t.Errorf("Want invalid secret rejected, got %v", err)
+	}
+	if _, err := NewSecretAuthenticator("shared", "").Authenticate(""); err != ErrUnauthorized {
+		t.Errorf("Want empty secret rejected, got %v", err)
+	}
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	auth := NewTokenAuthenticator(map[string]string{
+		"agent-1": "token-1",
+		"agent-2": "token-2",
+	})
+	if agent, err := auth.Authenticate("token-2"); err != nil || agent.ID != "agent-2" {
+		t.Errorf("Want token mapped to the agent identity, got %v", err)
+	}
+	if _, err := auth.Authenticate("token-3"); err != ErrUnauthorized {
+		t.Errorf("Want unknown token rejected, got %v", err)
+	}
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	secret := []byte("correct-horse")
+	now := time.Now().Unix()
+
+	tests := []struct {
+		token string
+		valid bool
+	}{
+		{signJWT(`{"alg":"HS256"}`, fmt.Sprintf(`{"sub":"agent-1","exp":%d}`, now+60), secret), true},
+		{signJWT(`{"alg":"HS256"}`, fmt.Sprintf(`{"sub":"agent-1","exp":%d}`, now-60), secret), false},
+		{signJWT(`{"alg":"HS256"}`, `{"sub":"agent-1"}`, secret), false},
+		{signJWT(`{"alg":"HS256"}`, fmt.Sprintf(`{"sub":"agent-1","exp":%d,"nbf":%d}`, now+120, now+60), secret), false},
+		{signJWT(`{"alg":"HS256"}`, fmt.Sprintf(`{"sub":"agent-1","exp":%d}`, now+60), []byte("battery-staple")), false},
+		{signJWT(`{"alg":"none"}`, fmt.Sprintf(`{"sub":"agent-1","exp":%d}`, now+60), secret), false},
+		{"not.a.token", false},
+	}
+
+	auth := NewJWTAuthenticator(secret)
+	for i, test := range tests {
+		agent, err := auth.Authenticate(test.token)
+		if test.valid && (err != nil || agent.ID != "agent-1") {
+			t.Errorf("Want token %d accepted, got %v", i, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Want token %d rejected", i)
+		}
+	}
+}
+
+func TestServerAuthenticator(t *testing.T) {
+	auth := NewTokenAuthenticator(map[string]string{"agent-1": "token-1"})
+	server := httptest.NewServer(NewServer(new(mockPeer), WithAuthenticator(auth)))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Authorization", "Bearer token-2")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Want unauthorized status, got %d", res.StatusCode)
+	}
+}
+
+func TestServerAuthenticatorExpired(t *testing.T) {
+	var expired int32
+	auth := AuthenticatorFunc(func(token string) (*Agent, error) {
+		if token != "token-1" || atomic.LoadInt32(&expired) != 0 {
+			return nil, ErrUnauthorized
+		}
+		return &Agent{ID: "agent-1"}, nil
+	})
+	server := httptest.NewServer(NewServer(new(mockPeer), WithAuthenticator(auth)))
+	defer server.Close()
+
+	client, err := NewClient("ws"+strings.TrimPrefix(server.URL, "http"), WithToken("token-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if _, err := client.Next(ctx, NoFilter); err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&expired, 1)
+	if _, err := client.Next(ctx, NoFilter); err == nil || !strings.Contains(err.Error(), ErrUnauthorized.Error()) {
+		t.Errorf("Want requests rejected once the token expires, got %v", err)
+	}
+}
+
+func TestGrpcAuthInterceptor(t *testing.T) {
+	auth := NewTokenAuthenticator(map[string]string{"agent-1": "token-1"})
+	interceptor := NewGrpcAuthInterceptor(auth)
+
+	var agent *Agent
+	handler := func(ctx netcontext.Context, req interface{}) (interface{}, error) {
+		agent, _ = AgentFromContext(ctx)
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/proto.Drone/Next"}
+
+	md := metadata.Pairs("authorization", "Bearer token-1")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	if _, err := interceptor(ctx, nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if agent == nil || agent.ID != "agent-1" {
+		t.Errorf("Want agent identity in the request context")
+	}
+
+	_, err := interceptor(context.Background(), nil, info, handler)
+	if grpc.Code(err) != codes.Unauthenticated {
+		t.Errorf("Want unauthenticated code without a token, got %v", err)
+	}
+
+	health := &grpc.UnaryServerInfo{FullMethod: "/proto.Health/Check"}
+	if _, err := interceptor(context.Background(), nil, health, handler); err != nil {
+		t.Errorf("Want health checks not authenticated, got %v", err)
+	}
+}
+
+func TestGrpcAgentMismatch(t *testing.T) {
+	auth := NewTokenAuthenticator(map[string]string{"agent-1": "token-1"})
+	handler := func(ctx netcontext.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/proto.Drone/Next"}
+	tokenCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token-1"))
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "agent-2"}}
+	tlsInfo := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}
+	certCtx := peer.NewContext(tokenCtx, &peer.Peer{AuthInfo: tlsInfo})
+
+	// the interceptors reject the request in either order.
+	tokenFirst := func(ctx netcontext.Context, req interface{}) (interface{}, error) {
+		return NewGrpcCertInterceptor()(ctx, req, info, handler)
+	}
+	if _, err := NewGrpcAuthInterceptor(auth)(certCtx, nil, info, tokenFirst); grpc.Code(err) != codes.Unauthenticated {
+		t.Errorf("Want certificate for another agent rejected, got %v", err)
+	}
+	certFirst := func(ctx netcontext.Context, req interface{}) (interface{}, error) {
+		return NewGrpcAuthInterceptor(auth)(ctx, req, info, handler)
+	}
+	if _, err := NewGrpcCertInterceptor()(certCtx, nil, info, certFirst); grpc.Code(err) != codes.Unauthenticated {
+		t.Errorf("Want token for another agent rejected, got %v", err)
+	}
+
+	// shared secrets do not identify the agent, and match any
+	// certificate.
+	secret := NewSecretAuthenticator("", "token-1")
+	var agent *Agent
+	record := func(ctx netcontext.Context, req interface{}) (interface{}, error) {
+		agent, _ = AgentFromContext(ctx)
+		return nil, nil
+	}
+	chain := func(ctx netcontext.Context, req interface{}) (interface{}, error) {
+		return NewGrpcCertInterceptor()(ctx, req, info, record)
+	}
+	if _, err := NewGrpcAuthInterceptor(secret)(certCtx, nil, info, chain); err != nil || agent == nil || agent.ID != "agent-2" {
+		t.Errorf("Want certificate identity used with a shared secret, got %v", err)
+	}
+}
+
+// helper function returns a signed json web token.
+func signJWT(header, claims string, secret []byte) string {
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(header)) + "." + enc.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
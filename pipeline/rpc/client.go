@@ -29,16 +29,19 @@ type (
 	uploadReq struct {
 		ID   string `json:"id"`
 		File *File  `json:"file"`
+		Key  string `json:"key,omitempty"`
 	}
 
 	updateReq struct {
 		ID    string `json:"id"`
 		State State  `json:"state"`
+		Key   string `json:"key,omitempty"`
 	}
 
 	logReq struct {
 		ID   string `json:"id"`
 		Line *Line  `json:"line"`
+		Key  string `json:"key,omitempty"`
 	}
 )
 
@@ -51,6 +54,10 @@ const (
 type Client struct {
 	sync.Mutex
 
+	// dial serializes re-connects, so that calls failing together on
+	// a dropped connection open a single new connection.
+	dial sync.Mutex
+
 	conn     *jsonrpc2.Conn
 	done     bool
 	retry    int
@@ -58,6 +65,11 @@ type Client struct {
 	endpoint string
 	token    string
 	headers  map[string][]string
+
+	certs      *Certificates
+	serverName string
+
+	reconnect func(error)
 }
 
 // NewClient returns a new Client.
@@ -71,7 +83,7 @@ func NewClient(endpoint string, opts ...Option) (*Client, error) {
 	for _, opt := range opts {
 		opt(cli)
 	}
-	err := cli.openRetry()
+	err := cli.openRetry(nil)
 	return cli, err
 }
 
@@ -93,13 +105,14 @@ func (t *Client) Wait(c context.Context, id string) error {
 
 // Init signals the pipeline is initialized.
 func (t *Client) Init(c context.Context, id string, state State) error {
-	params := updateReq{id, state}
+	params := updateReq{ID: id, State: state}
 	return t.call(c, methodInit, &params, nil)
 }
 
 // Done signals the pipeline is complete.
 func (t *Client) Done(c context.Context, id string, state State) error {
-	params := updateReq{id, state}
+	key, _ := IdempotencyKeyFromContext(c)
+	params := updateReq{ID: id, State: state, Key: key}
 	return t.call(c, methodDone, &params, nil)
 }
 
@@ -110,19 +123,22 @@ func (t *Client) Extend(c context.Context, id string) error {
 
 // Update updates the pipeline state.
 func (t *Client) Update(c context.Context, id string, state State) error {
-	params := updateReq{id, state}
+	key, _ := IdempotencyKeyFromContext(c)
+	params := updateReq{ID: id, State: state, Key: key}
 	return t.call(c, methodUpdate, &params, nil)
 }
 
 // Log writes the pipeline log entry.
 func (t *Client) Log(c context.Context, id string, line *Line) error {
-	params := logReq{id, line}
+	key, _ := IdempotencyKeyFromContext(c)
+	params := logReq{ID: id, Line: line, Key: key}
 	return t.call(c, methodLog, &params, nil)
 }
 
 // Upload uploads the pipeline artifact.
 func (t *Client) Upload(c context.Context, id string, file *File) error {
-	params := uploadReq{id, file}
+	key, _ := IdempotencyKeyFromContext(c)
+	params := uploadReq{ID: id, File: file, Key: key}
 	return t.call(c, methodUpload, params, nil)
 }
 
@@ -130,14 +146,16 @@ func (t *Client) Upload(c context.Context, id string, file *File) error {
 func (t *Client) Close() error {
 	t.Lock()
 	t.done = true
+	conn := t.conn
 	t.Unlock()
-	return t.conn.Close()
+	return conn.Close()
 }
 
 // call makes the remote prodedure call. If the call fails due to connectivity
 // issues the connection is re-establish and call re-attempted.
 func (t *Client) call(ctx context.Context, name string, req, res interface{}) error {
-	if err := t.conn.Call(ctx, name, req, res); err == nil {
+	conn := t.connection()
+	if err := conn.Call(ctx, name, req, res); err == nil {
 		return nil
 	} else if err != jsonrpc2.ErrClosed && err != io.ErrUnexpectedEOF {
 		log.Printf("rpc: error making call: %s", err)
@@ -145,18 +163,33 @@ func (t *Client) call(ctx context.Context, name string, req, res interface{}) er
 	} else {
 		log.Printf("rpc: error making call: connection closed: %s", err)
 	}
-	if err := t.openRetry(); err != nil {
+	if err := t.openRetry(conn); err != nil {
 		return err
 	}
-	return t.conn.Call(ctx, name, req, res)
+	return t.connection().Call(ctx, name, req, res)
+}
+
+// connection returns the current connection. The connection is shared
+// by concurrent calls and replaced when the client re-connects.
+func (t *Client) connection() *jsonrpc2.Conn {
+	t.Lock()
+	defer t.Unlock()
+	return t.conn
 }
 
-// openRetry opens the connection and will retry on failure until
-// the connection is successfully open, or the maximum retry count
-// is exceeded.
-func (t *Client) openRetry() error {
+// openRetry replaces the failed connection and will retry on failure
+// until the connection is successfully open, or the maximum retry count
+// is exceeded. If the failed connection was already replaced by another
+// call, the new connection is used.
+func (t *Client) openRetry(failed *jsonrpc2.Conn) error {
+	t.dial.Lock()
+	defer t.dial.Unlock()
+	if t.connection() != failed {
+		return nil
+	}
+	var err error
 	for i := 0; i < t.retry; i++ {
-		err := t.open()
+		err = t.open()
 		if err == nil {
 			break
 		}
@@ -167,11 +200,15 @@ func (t *Client) openRetry() error {
 		log.Printf("rpc: error re-connecting: %s", err)
 		<-time.After(t.backoff)
 	}
-	return nil
+	if failed != nil && t.reconnect != nil {
+		t.reconnect(err)
+	}
+	return err
 }
 
 // open creates a websocket connection to a peer and establishes a json
-// rpc communication stream.
+// rpc communication stream. The connection it replaces, if any, is
+// closed.
 func (t *Client) open() error {
 	t.Lock()
 	defer t.Unlock()
@@ -185,11 +222,23 @@ func (t *Client) open() error {
 	for key, value := range t.headers {
 		header[key] = value
 	}
-	conn, _, err := websocket.DefaultDialer.Dial(t.endpoint, http.Header(header))
+	dialer := websocket.DefaultDialer
+	if t.certs != nil {
+		// the tls configuration is loaded for each connection so that
+		// rotated certificates are used when re-connecting.
+		dialer = &websocket.Dialer{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: t.certs.ClientConfig(t.serverName),
+		}
+	}
+	conn, _, err := dialer.Dial(t.endpoint, http.Header(header))
 	if err != nil {
 		return err
 	}
 	stream := websocketrpc.NewObjectStream(conn)
+	if t.conn != nil {
+		t.conn.Close()
+	}
 	t.conn = jsonrpc2.NewConn(context.Background(), stream, nil)
 	return nil
 }
@@ -74,6 +74,7 @@ func (c *client) Next(ctx context.Context, f Filter) (*Pipeline, error) {
 	p := new(Pipeline)
 	p.ID = res.GetPipeline().GetId()
 	p.Timeout = res.GetPipeline().GetTimeout()
+	p.TraceID = res.GetPipeline().GetTraceId()
 	p.Config = new(backend.Config)
 	json.Unmarshal(res.GetPipeline().GetPayload(), p.Config)
 	return p, nil
@@ -142,6 +143,7 @@ func (c *client) Init(ctx context.Context, id string, state State) (err error) {
 
 // Done signals the pipeline is complete.
 func (c *client) Done(ctx context.Context, id string, state State) (err error) {
+	ctx = outgoingKey(ctx)
 	req := new(proto.DoneRequest)
 	req.Id = id
 	req.State = new(proto.State)
@@ -203,6 +205,7 @@ func (c *client) Extend(ctx context.Context, id string) (err error) {
 
 // Update updates the pipeline state.
 func (c *client) Update(ctx context.Context, id string, state State) (err error) {
+	ctx = outgoingKey(ctx)
 	req := new(proto.UpdateRequest)
 	req.Id = id
 	req.State = new(proto.State)
@@ -237,6 +240,7 @@ func (c *client) Update(ctx context.Context, id string, state State) (err error)
 
 // Upload uploads the pipeline artifact.
 func (c *client) Upload(ctx context.Context, id string, file *File) (err error) {
+	ctx = outgoingKey(ctx)
 	req := new(proto.UploadRequest)
 	req.Id = id
 	req.File = new(proto.File)
@@ -272,6 +276,7 @@ func (c *client) Upload(ctx context.Context, id string, file *File) (err error)
 
 // Log writes the pipeline log entry.
 func (c *client) Log(ctx context.Context, id string, line *Line) (err error) {
+	ctx = outgoingKey(ctx)
 	req := new(proto.LogRequest)
 	req.Id = id
 	req.Line = new(proto.Line)
@@ -56,6 +56,9 @@ func (c *healthClient) Check(ctx context.Context) (bool, error) {
 		default:
 			return false, err
 		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
 		<-time.After(backoff)
 	}
 
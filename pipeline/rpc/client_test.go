@@ -1 +1,114 @@
 package rpc
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientReconnect(t *testing.T) {
+	peer := &blockingPeer{started: make(chan struct{}, 10)}
+	listener := &trackingListener{Listener: mustListen(t)}
+	server := httptest.NewUnstartedServer(NewServer(peer))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	var reconnects int32
+	client, err := NewClient("ws"+strings.TrimPrefix(server.URL, "http"),
+		WithBackoff(10*time.Millisecond),
+		WithReconnectFunc(func(error) { atomic.AddInt32(&reconnects, 1) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	const callers = 5
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Next(context.Background(), Filter{}); err != nil {
+				t.Errorf("Want call retried on the new connection, got %v", err)
+			}
+		}()
+	}
+	for i := 0; i < callers; i++ {
+		<-peer.started
+	}
+
+	// drop the connection while every call is in flight.
+	atomic.StoreInt32(&peer.dropped, 1)
+	listener.closeAll()
+	wg.Wait()
+
+	if got := listener.accepted(); got != 2 {
+		t.Errorf("Want a single connection opened to re-connect, got %d connections", got)
+	}
+	if got := atomic.LoadInt32(&reconnects); got != 1 {
+		t.Errorf("Want one re-connect reported, got %d", got)
+	}
+}
+
+// blockingPeer blocks calls to Next until the connection is dropped.
+type blockingPeer struct {
+	mockPeer
+	started chan struct{}
+	dropped int32
+}
+
+func (p *blockingPeer) Next(c context.Context, f Filter) (*Pipeline, error) {
+	if atomic.LoadInt32(&p.dropped) != 0 {
+		return nil, nil
+	}
+	p.started <- struct{}{}
+	<-c.Done()
+	return nil, c.Err()
+}
+
+// trackingListener records the accepted connections, so that the test
+// can drop them.
+type trackingListener struct {
+	net.Listener
+	sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.Lock()
+		l.conns = append(l.conns, conn)
+		l.Unlock()
+	}
+	return conn, err
+}
+
+func (l *trackingListener) accepted() int {
+	l.Lock()
+	defer l.Unlock()
+	return len(l.conns)
+}
+
+func (l *trackingListener) closeAll() {
+	l.Lock()
+	defer l.Unlock()
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+}
+
+func mustListen(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listener
+}
@@ -0,0 +1,78 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// idempotencyHeader is the grpc metadata key used to send the
+// idempotency key.
+const idempotencyHeader = "idempotency-key"
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a copy of the context that carries the
+// idempotency key. The key is sent with Update, Log, Upload and Done
+// calls, so that the server can drop calls that are delivered more
+// than once.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the idempotency key of the call,
+// if the agent provided one.
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKey{}).(string)
+	return key, ok && key != ""
+}
+
+// IsRejected returns true if the error was returned by the server, as
+// opposed to an error connecting to the server. Calls rejected by the
+// server are not expected to succeed when retried.
+func IsRejected(err error) bool {
+	if _, ok := err.(*jsonrpc2.Error); ok {
+		return true
+	}
+	status, ok := status.FromError(err)
+	if !ok || err == nil {
+		return false
+	}
+	switch status.Code() {
+	case
+		codes.Canceled,
+		codes.Aborted,
+		codes.DataLoss,
+		codes.DeadlineExceeded,
+		codes.Internal,
+		codes.Unavailable:
+		return false
+	default:
+		return true
+	}
+}
+
+// helper function returns a copy of the context that sends the
+// idempotency key as grpc metadata.
+func outgoingKey(ctx context.Context) context.Context {
+	key, ok := IdempotencyKeyFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = metadata.Join(md, metadata.Pairs(idempotencyHeader, key))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// helper function returns a copy of the context that carries the
+// idempotency key received as grpc metadata.
+func incomingKey(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md[idempotencyHeader]) == 0 {
+		return ctx
+	}
+	return WithIdempotencyKey(ctx, md[idempotencyHeader][0])
+}
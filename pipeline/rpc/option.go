@@ -28,6 +28,26 @@ func WithToken(t string) Option {
 	}
 }
 
+// WithReconnectFunc configures a function that is called each time
+// the client re-connects to the server after the connection is closed,
+// with the re-connect error, if any.
+func WithReconnectFunc(fn func(error)) Option {
+	return func(c *Client) {
+		c.reconnect = fn
+	}
+}
+
+// WithTLS configures the client to connect to the server with mutual
+// tls, using the certificates loaded from disk when the connection is
+// opened. The server name overrides the host name used to verify the
+// server certificate.
+func WithTLS(certs *Certificates, serverName string) Option {
+	return func(c *Client) {
+		c.certs = certs
+		c.serverName = serverName
+	}
+}
+
 // WithHeader configures the client header.
 func WithHeader(key, value string) Option {
 	return func(c *Client) {
@@ -31,6 +31,7 @@ type (
 		ID      string          `json:"id"`
 		Config  *backend.Config `json:"config"`
 		Timeout int64           `json:"timeout"`
+		TraceID string          `json:"trace_id,omitempty"`
 	}
 
 	// File defines a pipeline artifact.
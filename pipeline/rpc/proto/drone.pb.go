@@ -262,6 +262,7 @@ type Pipeline struct {
 	Id      string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 	Timeout int64  `protobuf:"varint,2,opt,name=timeout" json:"timeout,omitempty"`
 	Payload []byte `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	TraceId string `protobuf:"bytes,4,opt,name=trace_id,json=traceId" json:"trace_id,omitempty"`
 }
 
 func (m *Pipeline) Reset()                    { *m = Pipeline{} }
@@ -290,6 +291,13 @@ func (m *Pipeline) GetPayload() []byte {
 	return nil
 }
 
+func (m *Pipeline) GetTraceId() string {
+	if m != nil {
+		return m.TraceId
+	}
+	return ""
+}
+
 type HealthCheckRequest struct {
 	Service string `protobuf:"bytes,1,opt,name=service" json:"service,omitempty"`
 }
@@ -905,54 +913,55 @@ var _Health_serviceDesc = grpc.ServiceDesc{
 func init() { proto1.RegisterFile("drone.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 780 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x54, 0x5f, 0x4f, 0xd3, 0x50,
-	0x14, 0xa7, 0x5b, 0xdb, 0x6d, 0xa7, 0x0c, 0xe6, 0x15, 0x4d, 0x99, 0x31, 0x2c, 0x4d, 0x4c, 0xa6,
-	0x26, 0x4d, 0x9c, 0x26, 0x22, 0x89, 0x06, 0x03, 0x43, 0x88, 0x73, 0x98, 0x3b, 0x91, 0x47, 0x52,
-	0xd6, 0x0b, 0x34, 0x74, 0x6d, 0x6d, 0xef, 0xc8, 0xe6, 0x47, 0xf0, 0xd5, 0x57, 0x3f, 0x9d, 0xdf,
-	0xc1, 0x77, 0x73, 0xee, 0x6d, 0x4b, 0x07, 0x9b, 0x89, 0xe1, 0xa9, 0xe7, 0xcf, 0xef, 0xdc, 0x73,
-	0xce, 0xef, 0xd7, 0x7b, 0xc1, 0x70, 0xe3, 0x30, 0x60, 0x76, 0x14, 0x87, 0x3c, 0x24, 0x9a, 0xf8,
-	0x58, 0xbf, 0x15, 0x50, 0xf7, 0x3c, 0x9f, 0x11, 0x02, 0x6a, 0xe0, 0x8c, 0x98, 0xa9, 0xb4, 0x94,
-	0x76, 0x8d, 0x0a, 0x1b, 0x63, 0x51, 0x1c, 0x0e, 0xcd, 0x92, 0x8c, 0xa1, 0x8d, 0xb1, 0x91, 0x37,
-	0x62, 0x66, 0x59, 0xc6, 0xd0, 0xc6, 0x18, 0xc7, 0x98, 0xda, 0x52, 0xda, 0x65, 0x2a, 0x6c, 0x8c,
-	0x25, 0xde, 0x77, 0x66, 0x6a, 0x2d, 0xa5, 0xad, 0x51, 0x61, 0x63, 0xcc, 0x75, 0xb8, 0x63, 0xea,
-	0x2d, 0xa5, 0xbd, 0x4c, 0x85, 0x4d, 0x9e, 0x82, 0x3a, 0x62, 0xdc, 0x31, 0x2b, 0xad, 0x72, 0xdb,
-	0xe8, 0x3c, 0x90, 0xd3, 0xd9, 0x38, 0x92, 0xfd, 0x89, 0x71, 0xa7, 0x1b, 0xf0, 0x78, 0x4a, 0x05,
-	0xa4, 0xf9, 0x1a, 0x6a, 0x79, 0x88, 0x34, 0xa0, 0x7c, 0xc9, 0xa6, 0xe9, 0xb8, 0x68, 0x92, 0x35,
-	0xd0, 0xae, 0x1c, 0x7f, 0xcc, 0xd2, 0x71, 0xa5, 0xb3, 0x55, 0xda, 0x54, 0xac, 0x5f, 0x0a, 0x68,
-	0x03, 0xee, 0xf0, 0xf9, 0x5b, 0x3e, 0x04, 0x9d, 0x4d, 0x3c, 0xce, 0x5c, 0x51, 0x58, 0xa5, 0xa9,
-	0x47, 0x1e, 0x41, 0x0d, 0xad, 0x93, 0x61, 0xe8, 0xca, 0x75, 0x35, 0x5a, 0xc5, 0xc0, 0x4e, 0xe8,
-	0x32, 0x62, 0x42, 0x25, 0xe1, 0x4e, 0x8c, 0x55, 0x72, 0xeb, 0xcc, 0x25, 0x4d, 0xa8, 0x9e, 0x79,
-	0x81, 0x97, 0x5c, 0x30, 0x57, 0x2c, 0x5f, 0xa6, 0xb9, 0x8f, 0x23, 0xb2, 0x38, 0x0e, 0x63, 0xc1,
-	0x40, 0x8d, 0x4a, 0xc7, 0xa2, 0xa0, 0xf6, 0xbc, 0xe0, 0x9a, 0x6e, 0x65, 0x96, 0x6e, 0x41, 0x6d,
-	0xa9, 0x40, 0x6d, 0x03, 0xca, 0x51, 0x98, 0xa4, 0x23, 0xa1, 0x89, 0x91, 0x70, 0xcc, 0xc5, 0x24,
-	0x35, 0x8a, 0xa6, 0xf5, 0x43, 0x01, 0x7d, 0xcf, 0xf3, 0x39, 0x8b, 0xc9, 0x0b, 0xd0, 0x7d, 0xe7,
-	0x94, 0xf9, 0x89, 0xa9, 0x08, 0x8e, 0xd7, 0xaf, 0x39, 0xe6, 0x2c, 0xb6, 0x7b, 0x22, 0x27, 0x79,
-	0x4e, 0x81, 0xd8, 0x95, 0x4d, 0xa2, 0x38, 0x13, 0x1e, 0xed, 0xe6, 0x1b, 0x30, 0x0a, 0xd0, 0xff,
-	0xe2, 0xbf, 0x0f, 0xd5, 0xcf, 0x5e, 0xc4, 0x7c, 0x5c, 0x72, 0x05, 0x4a, 0x9e, 0x9b, 0x96, 0x95,
-	0x3c, 0x17, 0x89, 0xc4, 0xa5, 0x70, 0x7c, 0xb9, 0x63, 0xe6, 0x62, 0x26, 0x72, 0xa6, 0x7e, 0xe8,
-	0xb8, 0x62, 0xd5, 0x65, 0x9a, 0xb9, 0x96, 0x0d, 0x64, 0x9f, 0x39, 0x3e, 0xbf, 0xd8, 0xb9, 0x60,
-	0xc3, 0x4b, 0xca, 0xbe, 0x8d, 0x59, 0x22, 0xf0, 0x09, 0x8b, 0xaf, 0xbc, 0x61, 0x26, 0x6f, 0xe6,
-	0x5a, 0x3f, 0x15, 0xb8, 0x3f, 0x53, 0x90, 0x44, 0x61, 0x90, 0x30, 0xb2, 0x0d, 0x7a, 0xc2, 0x1d,
-	0x3e, 0x4e, 0x44, 0xc1, 0x4a, 0xa7, 0x9d, 0x32, 0x33, 0x07, 0x6b, 0x0f, 0xf0, 0xac, 0xe0, 0x7c,
-	0x20, 0xf0, 0x34, 0xad, 0xb3, 0xb6, 0xa0, 0x3e, 0x93, 0x20, 0x06, 0x54, 0x8e, 0xfa, 0x1f, 0xfb,
-	0x87, 0xc7, 0xfd, 0xc6, 0x12, 0x3a, 0x83, 0x2e, 0xfd, 0x7a, 0xd0, 0xff, 0xd0, 0x50, 0xc8, 0x2a,
-	0x18, 0xfd, 0xc3, 0x2f, 0x27, 0x59, 0xa0, 0x64, 0xbd, 0x02, 0xa3, 0xcf, 0x26, 0x3c, 0x1b, 0xff,
-	0x09, 0xe8, 0x67, 0x42, 0x11, 0x31, 0x8c, 0xd1, 0xa9, 0xcf, 0xc8, 0x44, 0xd3, 0xa4, 0xb5, 0x09,
-	0x35, 0x59, 0x15, 0xf9, 0x53, 0xf2, 0x1c, 0xaa, 0x51, 0x4a, 0x6c, 0x5a, 0xb5, 0x9a, 0x56, 0x65,
-	0x7c, 0xd3, 0x1c, 0x60, 0xbd, 0x07, 0xe3, 0x20, 0xf0, 0xf2, 0x7e, 0x37, 0x85, 0xb0, 0x40, 0xc3,
-	0xa5, 0xa4, 0x7c, 0x46, 0x67, 0x39, 0x3d, 0x48, 0xdc, 0x1b, 0x2a, 0x53, 0xd6, 0x63, 0x30, 0x8e,
-	0x9d, 0x85, 0x47, 0x60, 0x87, 0xdd, 0x30, 0x60, 0x77, 0xe9, 0xb0, 0x01, 0xf5, 0xee, 0x84, 0xb3,
-	0xc0, 0x5d, 0xd4, 0x63, 0x1b, 0xea, 0x47, 0x11, 0xfe, 0x05, 0x8b, 0xba, 0x6c, 0x80, 0x7a, 0xe6,
-	0xf9, 0x59, 0x13, 0xa3, 0xf0, 0xa0, 0x50, 0x91, 0xb0, 0x76, 0xf0, 0x04, 0x17, 0x7b, 0xde, 0x61,
-	0xce, 0xb7, 0x00, 0xbd, 0xf0, 0xfc, 0x1f, 0x33, 0x08, 0x4d, 0x66, 0x67, 0xc0, 0x4b, 0x4e, 0x45,
-	0xc2, 0xaa, 0x80, 0xd6, 0x1d, 0x45, 0x7c, 0xda, 0xf9, 0x53, 0x02, 0x6d, 0x17, 0x9f, 0x65, 0x62,
-	0x83, 0x8a, 0xc2, 0x12, 0x92, 0xa2, 0x0b, 0xff, 0x46, 0xb3, 0x31, 0x13, 0x8b, 0xfc, 0xa9, 0xb5,
-	0x44, 0x9e, 0x81, 0x8a, 0x72, 0xe6, 0xf8, 0x82, 0xb6, 0xcd, 0x6c, 0x64, 0xd1, 0x43, 0x62, 0x51,
-	0xb7, 0x1c, 0x5b, 0x10, 0x71, 0x1e, 0x16, 0x45, 0xcc, 0xb1, 0x05, 0x45, 0x6f, 0x61, 0x6d, 0xd0,
-	0xa5, 0x5a, 0x64, 0x2d, 0xcb, 0x14, 0xc5, 0x9b, 0x87, 0x97, 0xd4, 0xe7, 0xf8, 0x19, 0x25, 0xe6,
-	0xe3, 0x51, 0xec, 0x02, 0xbe, 0xa0, 0xfd, 0x2d, 0x7c, 0x1b, 0xca, 0xbd, 0xf0, 0x9c, 0xdc, 0xcb,
-	0x08, 0xcf, 0x15, 0xba, 0x89, 0xec, 0xec, 0x83, 0x2e, 0x6f, 0x39, 0x79, 0x07, 0x9a, 0xb8, 0xe9,
-	0x64, 0x7d, 0xde, 0xed, 0x97, 0xd5, 0xcd, 0xc5, 0x0f, 0xc3, 0xa9, 0x2e, 0x52, 0x2f, 0xff, 0x06,
-	0x00, 0x00, 0xff, 0xff, 0x59, 0x78, 0xda, 0x5d, 0x5e, 0x07, 0x00, 0x00,
+	// 786 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x03, 0xa5, 0x54, 0xdf, 0x4f, 0xd3, 0x50,
+	0x14, 0xa6, 0xdb, 0xda, 0x6d, 0xa7, 0x4c, 0xe6, 0x15, 0xcd, 0x98, 0x31, 0x90, 0x26, 0x26, 0x53,
+	0x93, 0x26, 0x4e, 0x13, 0x91, 0x44, 0x83, 0x81, 0x29, 0x44, 0x1c, 0xe6, 0x4e, 0xe4, 0x91, 0x94,
+	0xf5, 0x02, 0x0d, 0xa5, 0xad, 0xed, 0x1d, 0xd9, 0x7c, 0xf6, 0xc9, 0x57, 0x5f, 0xfd, 0xeb, 0xfc,
+	0x1f, 0x7c, 0xf7, 0x9e, 0x7b, 0xdb, 0xd2, 0xc1, 0x20, 0x31, 0x3c, 0xf5, 0x9c, 0xef, 0x7c, 0xe7,
+	0x9e, 0x1f, 0x5f, 0xef, 0x05, 0xd3, 0x8d, 0xc3, 0x80, 0xd9, 0x51, 0x1c, 0xf2, 0x90, 0xe8, 0xf2,
+	0x63, 0xfd, 0xd1, 0xa0, 0xf2, 0xde, 0xf3, 0x19, 0x21, 0x50, 0x09, 0x9c, 0x33, 0xd6, 0xd2, 0x56,
+	0xb4, 0x4e, 0x9d, 0x4a, 0x1b, 0x31, 0xc1, 0x1a, 0xb6, 0x4a, 0x0a, 0x43, 0x1b, 0xb1, 0x33, 0x4f,
+	0xf0, 0xca, 0x0a, 0x43, 0x1b, 0x31, 0x8e, 0x58, 0x45, 0x60, 0x65, 0x2a, 0x6d, 0xc4, 0x12, 0xef,
+	0x3b, 0x6b, 0xe9, 0x02, 0xd3, 0xa9, 0xb4, 0x11, 0x73, 0x1d, 0xee, 0xb4, 0x0c, 0x81, 0xcd, 0x53,
+	0x69, 0x93, 0x27, 0xe2, 0x3c, 0x26, 0xb0, 0xea, 0x4a, 0xb9, 0x63, 0x76, 0xef, 0xab, 0xee, 0x6c,
+	0x6c, 0xc9, 0xfe, 0x24, 0xf0, 0x5e, 0xc0, 0xe3, 0x09, 0x95, 0x94, 0xf6, 0x2b, 0xa8, 0xe7, 0x10,
+	0x69, 0x42, 0xf9, 0x94, 0x4d, 0xd2, 0x76, 0xd1, 0x24, 0x8b, 0xa0, 0x9f, 0x3b, 0xfe, 0x88, 0xa5,
+	0xed, 0x2a, 0x67, 0xad, 0xb4, 0xaa, 0x59, 0xbf, 0x35, 0xd0, 0x07, 0xdc, 0xe1, 0xb3, 0xa7, 0x7c,
+	0x00, 0x06, 0x1b, 0x7b, 0x9c, 0xb9, 0x32, 0xb1, 0x46, 0x53, 0x8f, 0x3c, 0x84, 0x3a, 0x5a, 0x07,
+	0xc3, 0xd0, 0x55, 0xe3, 0xea, 0xb4, 0x86, 0xc0, 0x86, 0xf0, 0x49, 0x0b, 0xaa, 0x09, 0x77, 0x62,
+	0xcc, 0x52, 0x53, 0x67, 0x2e, 0x69, 0x43, 0xed, 0xc8, 0x0b, 0xbc, 0xe4, 0x44, 0x84, 0x74, 0x19,
+	0xca, 0x7d, 0x6c, 0x91, 0xc5, 0x71, 0x18, 0xcb, 0x0d, 0x88, 0x16, 0xa5, 0x63, 0x51, 0xa8, 0xec,
+	0x78, 0xc1, 0xc5, 0xba, 0xb5, 0xe9, 0x75, 0xcb, 0xd5, 0x96, 0x0a, 0xab, 0x15, 0xa3, 0x47, 0x61,
+	0x92, 0xb6, 0x84, 0x26, 0x22, 0xe1, 0x88, 0xcb, 0x4e, 0xc4, 0x32, 0x84, 0x69, 0xfd, 0xd4, 0xc0,
+	0x10, 0x4b, 0xe4, 0x2c, 0x26, 0xcf, 0xc1, 0xf0, 0x9d, 0x43, 0xe6, 0x27, 0xe2, 0x60, 0xdc, 0xf1,
+	0xd2, 0xc5, 0x8e, 0x45, 0xd8, 0xde, 0x91, 0x31, 0xb5, 0xe7, 0x94, 0x88, 0x55, 0xd9, 0x38, 0x8a,
+	0x33, 0xe1, 0xd1, 0x6e, 0xbf, 0x06, 0xb3, 0x40, 0xfd, 0xaf, 0xfd, 0xff, 0xd0, 0xa0, 0xf6, 0xd9,
+	0x8b, 0x98, 0x8f, 0x53, 0xde, 0x81, 0x92, 0xe7, 0xa6, 0x79, 0xc2, 0xc2, 0x4d, 0xe2, 0x54, 0xd8,
+	0xbf, 0x1a, 0x32, 0x73, 0x31, 0x12, 0x39, 0x13, 0x3f, 0x74, 0x5c, 0x39, 0xeb, 0x3c, 0xcd, 0x5c,
+	0xb2, 0x04, 0x35, 0x1e, 0x3b, 0x43, 0x76, 0xe0, 0xb9, 0xe9, 0xd0, 0x55, 0xe9, 0x6f, 0xdf, 0x14,
+	0xb2, 0x6c, 0x20, 0x5b, 0xcc, 0xf1, 0xf9, 0xc9, 0xc6, 0x09, 0x1b, 0x9e, 0x52, 0xf6, 0x6d, 0xc4,
+	0x12, 0x59, 0x25, 0x61, 0xf1, 0xb9, 0x37, 0xcc, 0xfe, 0x8a, 0xcc, 0xb5, 0x7e, 0x69, 0x70, 0x6f,
+	0x2a, 0x21, 0x89, 0xc2, 0x20, 0x61, 0x64, 0x1d, 0x0c, 0x21, 0x36, 0x1f, 0x25, 0x32, 0xe1, 0x4e,
+	0xb7, 0x93, 0x2e, 0x74, 0x06, 0xd7, 0x1e, 0xe0, 0x59, 0xc1, 0xf1, 0x40, 0xf2, 0x69, 0x9a, 0x67,
+	0xad, 0x41, 0x63, 0x2a, 0x40, 0x4c, 0xa8, 0xee, 0xf5, 0x3f, 0xf6, 0x77, 0xf7, 0xfb, 0xcd, 0x39,
+	0x74, 0x06, 0x3d, 0xfa, 0x75, 0xbb, 0xff, 0xa1, 0xa9, 0x91, 0x05, 0x30, 0xfb, 0xbb, 0x5f, 0x0e,
+	0x32, 0xa0, 0x64, 0xbd, 0x14, 0x00, 0x1b, 0xf3, 0xac, 0xfd, 0xc7, 0x60, 0x1c, 0x49, 0x21, 0x65,
+	0x33, 0x66, 0xb7, 0x31, 0xa5, 0x2e, 0x4d, 0x83, 0xd6, 0x2a, 0xd4, 0x55, 0x56, 0xe4, 0x4f, 0xc8,
+	0x33, 0xa8, 0x45, 0xa9, 0x1c, 0x69, 0xd6, 0x42, 0x9a, 0x95, 0xa9, 0x44, 0x73, 0x82, 0xf5, 0x0e,
+	0xcc, 0xed, 0xc0, 0xcb, 0xeb, 0x5d, 0x96, 0xcf, 0x02, 0x1d, 0x87, 0x52, 0xaa, 0x9b, 0xdd, 0xf9,
+	0xf4, 0x20, 0x79, 0xdd, 0xa8, 0x0a, 0x59, 0x8f, 0xc0, 0xdc, 0x77, 0xae, 0x3d, 0x02, 0x2b, 0x6c,
+	0x8a, 0x87, 0xe9, 0x36, 0x15, 0x96, 0xa1, 0xd1, 0x1b, 0x73, 0x16, 0xb8, 0xd7, 0xd5, 0x58, 0x87,
+	0xc6, 0x5e, 0x84, 0xff, 0xce, 0x75, 0x55, 0x96, 0xa1, 0x22, 0x56, 0x95, 0x15, 0x31, 0x0b, 0xef,
+	0x10, 0x95, 0x01, 0x6b, 0x03, 0x4f, 0x70, 0xb1, 0xe6, 0x2d, 0xfa, 0x7c, 0x03, 0xb0, 0x13, 0x1e,
+	0xdf, 0xd0, 0x83, 0xd4, 0x64, 0xba, 0x07, 0x7c, 0x1b, 0xa8, 0x0c, 0x58, 0x55, 0xd0, 0x7b, 0x67,
+	0x11, 0x9f, 0x74, 0xff, 0x96, 0x40, 0xdf, 0xc4, 0xd7, 0x9c, 0xd8, 0x50, 0x41, 0x61, 0x09, 0x49,
+	0xd9, 0x85, 0x7f, 0xa3, 0xdd, 0x9c, 0xc2, 0x84, 0xf2, 0xd6, 0x1c, 0x79, 0x0a, 0x15, 0x94, 0x33,
+	0xe7, 0x17, 0xb4, 0x6d, 0x67, 0x2d, 0xcb, 0x1a, 0x8a, 0x8b, 0xba, 0xe5, 0xdc, 0x82, 0x88, 0xb3,
+	0xb8, 0x28, 0x62, 0xce, 0x2d, 0x28, 0x7a, 0x85, 0x6b, 0x83, 0xa1, 0xd4, 0x22, 0x8b, 0x59, 0xa4,
+	0x28, 0xde, 0x2c, 0xbe, 0x5a, 0x7d, 0xce, 0x9f, 0x52, 0x62, 0x36, 0x5f, 0x3e, 0x14, 0x17, 0xfc,
+	0x82, 0xf6, 0x57, 0xf8, 0x1d, 0x28, 0x0b, 0x55, 0xc8, 0xdd, 0x6c, 0xe1, 0xb9, 0x42, 0x97, 0x99,
+	0xdd, 0x2d, 0x30, 0xd4, 0x2d, 0x27, 0x6f, 0x41, 0x97, 0x37, 0x9d, 0x2c, 0xcd, 0xba, 0xfd, 0x2a,
+	0xbb, 0x7d, 0xfd, 0xc3, 0x70, 0x68, 0xc8, 0xd0, 0x8b, 0x7f, 0xc0, 0x50, 0xd6, 0xde, 0x95, 0x07,
+	0x00, 0x00,
 }
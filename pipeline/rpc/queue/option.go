@@ -0,0 +1,31 @@
+package queue
+
+import "time"
+
+// Option configures a queue option.
+type Option func(*Queue)
+
+// WithLease configures the time an agent holds a pipeline before the
+// lease expires and the pipeline is returned to the queue.
+func WithLease(d time.Duration) Option {
+	return func(q *Queue) {
+		q.lease = d
+	}
+}
+
+// WithSink configures the sink used to store the pipeline logs and
+// artifacts. Logs and artifacts are discarded when no sink is
+// configured.
+func WithSink(sink Sink) Option {
+	return func(q *Queue) {
+		q.sink = sink
+	}
+}
+
+// WithRetention configures the time the status of a completed or
+// cancelled pipeline is kept before it is removed from the queue.
+func WithRetention(d time.Duration) Option {
+	return func(q *Queue) {
+		q.retention = d
+	}
+}
@@ -0,0 +1,426 @@
+// Package queue provides an in-memory pipeline queue that implements the
+// rpc.Peer interface, used to execute pipelines without an external
+// server.
+package queue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/rpc"
+)
+
+// Errors returned by the queue.
+var (
+	ErrNotFound     = errors.New("queue: pipeline not found")
+	ErrCancelled    = errors.New("queue: pipeline cancelled")
+	ErrLeaseExpired = errors.New("queue: pipeline lease expired")
+	ErrNotLeased    = errors.New("queue: pipeline leased by another agent")
+	ErrFilterExpr   = errors.New("queue: filter expressions are not supported")
+)
+
+// Pipeline status.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusDone      = "done"
+	StatusCancelled = "cancelled"
+)
+
+// defaultLease is the time an agent holds a pipeline before the lease
+// expires, unless the lease is extended.
+const defaultLease = 5 * time.Minute
+
+// defaultRetention is the time the status of a completed pipeline is
+// kept.
+const defaultRetention = 24 * time.Hour
+
+// Info defines the status of a queued pipeline.
+type Info struct {
+	ID     string            `json:"id"`
+	Status string            `json:"status"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Agent  string            `json:"agent,omitempty"`
+	State  rpc.State         `json:"state"`
+	Procs  []rpc.State       `json:"procs,omitempty"`
+}
+
+// Queue is an in-memory pipeline queue.
+type Queue struct {
+	mu        sync.Mutex
+	seq       int
+	lease     time.Duration
+	retention time.Duration
+	sink      Sink
+	pending   []*item
+	items     map[string]*item
+	changed   chan struct{}
+}
+
+type item struct {
+	info     Info
+	pipeline *rpc.Pipeline
+	timer    *time.Timer
+	lease    *lease
+
+	// idempotency keys of the calls applied to the running pipeline.
+	keys map[string]struct{}
+}
+
+// lease is signaled when the pipeline is complete, cancelled or the
+// agent lease expires.
+type lease struct {
+	done chan struct{}
+	err  error
+}
+
+// New returns a new in-memory queue.
+func New(opts ...Option) *Queue {
+	q := &Queue{
+		lease:     defaultLease,
+		retention: defaultRetention,
+		items:     map[string]*item{},
+		changed:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// Push adds the pipeline to the queue and returns the pipeline
+// identifier. The pipeline is only executed by agents advertising all of
+// the given labels.
+func (q *Queue) Push(p *rpc.Pipeline, labels map[string]string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	id := strconv.Itoa(q.seq)
+	clone := *p
+	clone.ID = id
+
+	it := &item{
+		pipeline: &clone,
+		lease:    &lease{done: make(chan struct{})},
+		info: Info{
+			ID:     id,
+			Status: StatusPending,
+			Labels: labels,
+		},
+	}
+	q.items[id] = it
+	q.pending = append(q.pending, it)
+	q.notify()
+	return id
+}
+
+// Cancel cancels the pipeline. Pending pipelines are removed from the
+// queue and agents running the pipeline are signaled through Wait.
+func (q *Queue) Cancel(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	it, ok := q.items[id]
+	if !ok {
+		return ErrNotFound
+	}
+	switch it.info.Status {
+	case StatusDone, StatusCancelled:
+		return nil
+	case StatusPending:
+		q.remove(it)
+	}
+	it.info.Status = StatusCancelled
+	q.finish(it, ErrCancelled)
+	q.evict(it)
+	return nil
+}
+
+// Info returns the status of the pipeline.
+func (q *Queue) Info(id string) (*Info, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	it, ok := q.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	info := it.info
+	info.Procs = append([]rpc.State(nil), it.info.Procs...)
+	return &info, nil
+}
+
+// Next returns the next pending pipeline matching the filter labels,
+// blocking until a pipeline is available or the context is cancelled.
+// Filter expressions are rejected, since the queue only matches labels.
+func (q *Queue) Next(c context.Context, f rpc.Filter) (*rpc.Pipeline, error) {
+	if f.Expr != "" {
+		return nil, ErrFilterExpr
+	}
+	for {
+		q.mu.Lock()
+		for _, it := range q.pending {
+			if !match(it.info.Labels, f.Labels) {
+				continue
+			}
+			q.remove(it)
+			it.info.Status = StatusRunning
+			it.info.Agent = agentID(c)
+			it.timer = time.AfterFunc(q.lease, func() { q.expire(it) })
+			q.mu.Unlock()
+			return it.pipeline, nil
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-c.Done():
+			return nil, c.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Wait blocks until the pipeline is complete. An error is returned if
+// the pipeline is cancelled or the lease expires.
+func (q *Queue) Wait(c context.Context, id string) error {
+	q.mu.Lock()
+	it, ok := q.items[id]
+	if !ok {
+		q.mu.Unlock()
+		return ErrNotFound
+	}
+	lease := it.lease
+	q.mu.Unlock()
+
+	select {
+	case <-c.Done():
+		return c.Err()
+	case <-lease.done:
+		return lease.err
+	}
+}
+
+// Init signals the pipeline is initialized.
+func (q *Queue) Init(c context.Context, id string, state rpc.State) error {
+	return q.update(c, id, func(it *item) {
+		it.info.State = state
+	})
+}
+
+// Done signals the pipeline is complete.
+func (q *Queue) Done(c context.Context, id string, state rpc.State) error {
+	if q.seen(c, id) {
+		return nil
+	}
+	return q.update(c, id, func(it *item) {
+		if it.info.State.Started != 0 && state.Started == 0 {
+			state.Started = it.info.State.Started
+		}
+		it.info.State = state
+		it.info.Status = StatusDone
+		q.finish(it, nil)
+		q.evict(it)
+
+		// only the done call can be repeated once the pipeline is
+		// complete.
+		it.keys = nil
+		q.applied(c, it)
+	})
+}
+
+// Extend extends the pipeline lease.
+func (q *Queue) Extend(c context.Context, id string) error {
+	return q.update(c, id, func(it *item) {
+		it.timer.Reset(q.lease)
+	})
+}
+
+// Update updates the pipeline step state.
+func (q *Queue) Update(c context.Context, id string, state rpc.State) error {
+	if q.seen(c, id) {
+		return nil
+	}
+	return q.update(c, id, func(it *item) {
+		q.applied(c, it)
+		for i, proc := range it.info.Procs {
+			if proc.Proc == state.Proc {
+				it.info.Procs[i] = state
+				return
+			}
+		}
+		it.info.Procs = append(it.info.Procs, state)
+	})
+}
+
+// Upload writes the pipeline artifact to the sink.
+func (q *Queue) Upload(c context.Context, id string, file *rpc.File) error {
+	if q.seen(c, id) {
+		return nil
+	}
+	if err := q.update(c, id, func(*item) {}); err != nil {
+		return err
+	}
+	if q.sink != nil {
+		if err := q.sink.Upload(id, file); err != nil {
+			return err
+		}
+	}
+	return q.update(c, id, func(it *item) { q.applied(c, it) })
+}
+
+// Log writes the pipeline log entry to the sink.
+func (q *Queue) Log(c context.Context, id string, line *rpc.Line) error {
+	if q.seen(c, id) {
+		return nil
+	}
+	if err := q.update(c, id, func(*item) {}); err != nil {
+		return err
+	}
+	if q.sink != nil {
+		if err := q.sink.Log(id, line); err != nil {
+			return err
+		}
+	}
+	return q.update(c, id, func(it *item) { q.applied(c, it) })
+}
+
+//
+// helper functions. The caller must hold the lock unless noted.
+//
+
+// update invokes fn with the running pipeline while holding the lock.
+// Calls from agents other than the agent holding the lease are
+// rejected, so that an agent whose lease expired cannot overwrite the
+// state of the pipeline once it is leased again. Agents are told apart
+// by their authenticated identity, so agents sharing an identity are
+// not.
+func (q *Queue) update(c context.Context, id string, fn func(*item)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	it, ok := q.items[id]
+	switch {
+	case !ok:
+		return ErrNotFound
+	case it.info.Status == StatusCancelled:
+		return ErrCancelled
+	case it.info.Status != StatusRunning:
+		return ErrNotFound
+	case it.info.Agent != agentID(c):
+		return ErrNotLeased
+	}
+	fn(it)
+	return nil
+}
+
+// seen returns true if the call with the idempotency key in the context
+// was already applied to the pipeline. The lock must not be held.
+func (q *Queue) seen(c context.Context, id string) bool {
+	key, ok := rpc.IdempotencyKeyFromContext(c)
+	if !ok {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	it, ok := q.items[id]
+	if !ok {
+		return false
+	}
+	_, ok = it.keys[key]
+	return ok
+}
+
+// applied records the idempotency key in the context, so that the call
+// is dropped if it is delivered again.
+func (q *Queue) applied(c context.Context, it *item) {
+	key, ok := rpc.IdempotencyKeyFromContext(c)
+	if !ok {
+		return
+	}
+	if it.keys == nil {
+		it.keys = map[string]struct{}{}
+	}
+	it.keys[key] = struct{}{}
+}
+
+// expire returns the pipeline to the queue when the agent fails to
+// extend the lease. The agent holding the expired lease is signaled
+// through Wait. The lock must not be held.
+func (q *Queue) expire(it *item) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if it.info.Status != StatusRunning {
+		return
+	}
+	q.finish(it, ErrLeaseExpired)
+
+	it.info.Status = StatusPending
+	it.info.Agent = ""
+	it.info.State = rpc.State{}
+	it.info.Procs = nil
+	it.keys = nil
+	it.lease = &lease{done: make(chan struct{})}
+	q.pending = append(q.pending, it)
+	q.notify()
+}
+
+// finish stops the lease timer and signals the pipeline is complete.
+func (q *Queue) finish(it *item, err error) {
+	if it.timer != nil {
+		it.timer.Stop()
+	}
+	it.lease.err = err
+	close(it.lease.done)
+}
+
+// evict removes the completed pipeline from the queue once the
+// retention period expires.
+func (q *Queue) evict(it *item) {
+	time.AfterFunc(q.retention, func() {
+		q.mu.Lock()
+		delete(q.items, it.info.ID)
+		q.mu.Unlock()
+	})
+}
+
+// remove removes the pipeline from the pending list.
+func (q *Queue) remove(it *item) {
+	for i, pending := range q.pending {
+		if pending == it {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// notify wakes up the agents blocked in Next.
+func (q *Queue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// agentID returns the identity of the agent making the call, or an
+// empty string if the agent is not authenticated.
+func agentID(c context.Context) string {
+	if agent, ok := rpc.AgentFromContext(c); ok {
+		return agent.ID
+	}
+	return ""
+}
+
+// match returns true if the agent labels include all of the pipeline
+// labels.
+func match(want, have map[string]string) bool {
+	for key, value := range want {
+		if have[key] != value {
+			return false
+		}
+	}
+	return true
+}
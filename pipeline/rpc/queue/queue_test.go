@@ -0,0 +1,251 @@
+package queue
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/rpc"
+)
+
+func TestQueueNext(t *testing.T) {
+	q := New()
+	gpu := q.Push(&rpc.Pipeline{}, map[string]string{"gpu": "true"})
+	other := q.Push(&rpc.Pipeline{}, nil)
+
+	ctx := context.Background()
+	p, err := q.Next(ctx, rpc.Filter{Labels: map[string]string{"platform": "linux/amd64"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != other {
+		t.Errorf("Want pipeline without labels, got %s", p.ID)
+	}
+	agent := rpc.WithAgent(ctx, &rpc.Agent{ID: "gpu-1"})
+	p, err = q.Next(agent, rpc.Filter{Labels: map[string]string{"gpu": "true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != gpu {
+		t.Errorf("Want pipeline matching labels, got %s", p.ID)
+	}
+
+	info, _ := q.Info(gpu)
+	if info.Status != StatusRunning {
+		t.Errorf("Want pipeline running, got %s", info.Status)
+	}
+	if info.Agent != "gpu-1" {
+		t.Errorf("Want agent identity recorded, got %q", info.Agent)
+	}
+}
+
+func TestQueueNextBlocks(t *testing.T) {
+	q := New()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Next(ctx, rpc.NoFilter); err != context.DeadlineExceeded {
+		t.Errorf("Want deadline exceeded when the queue is empty, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Push(&rpc.Pipeline{}, nil)
+	}()
+	p, err := q.Next(context.Background(), rpc.NoFilter)
+	if err != nil || p == nil {
+		t.Errorf("Want pipeline received when pushed, got %v", err)
+	}
+}
+
+func TestQueueDone(t *testing.T) {
+	q := New()
+	id := q.Push(&rpc.Pipeline{}, nil)
+	ctx := context.Background()
+	q.Next(ctx, rpc.NoFilter)
+
+	waited := make(chan error)
+	go func() {
+		waited <- q.Wait(ctx, id)
+	}()
+
+	q.Init(ctx, id, rpc.State{Started: 1})
+	q.Update(ctx, id, rpc.State{Proc: "build", ExitCode: 1})
+	q.Update(ctx, id, rpc.State{Proc: "build", ExitCode: 2})
+	q.Done(ctx, id, rpc.State{Exited: true, Finished: 2})
+
+	if err := <-waited; err != nil {
+		t.Errorf("Want wait to return without error when done, got %v", err)
+	}
+
+	info, _ := q.Info(id)
+	if info.Status != StatusDone || info.State.Started != 1 || info.State.Finished != 2 {
+		t.Errorf("Want done pipeline state recorded, got %+v", info)
+	}
+	if len(info.Procs) != 1 || info.Procs[0].ExitCode != 2 {
+		t.Errorf("Want latest step state recorded, got %+v", info.Procs)
+	}
+	if err := q.Update(ctx, id, rpc.State{}); err != ErrNotFound {
+		t.Errorf("Want update of completed pipeline rejected, got %v", err)
+	}
+}
+
+func TestQueueIdempotency(t *testing.T) {
+	q := New()
+	id := q.Push(&rpc.Pipeline{}, nil)
+	ctx := context.Background()
+	q.Next(ctx, rpc.NoFilter)
+
+	first := rpc.WithIdempotencyKey(ctx, "agent-1")
+	second := rpc.WithIdempotencyKey(ctx, "agent-2")
+	q.Update(first, id, rpc.State{Proc: "build", ExitCode: 1})
+	q.Update(second, id, rpc.State{Proc: "build", ExitCode: 2})
+	q.Update(first, id, rpc.State{Proc: "build", ExitCode: 1})
+
+	info, _ := q.Info(id)
+	if len(info.Procs) != 1 || info.Procs[0].ExitCode != 2 {
+		t.Errorf("Want duplicate update dropped, got %+v", info.Procs)
+	}
+
+	done := rpc.WithIdempotencyKey(ctx, "agent-3")
+	if err := q.Done(done, id, rpc.State{Exited: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Done(done, id, rpc.State{Exited: true}); err != nil {
+		t.Errorf("Want duplicate done accepted, got %v", err)
+	}
+	if err := q.Done(ctx, id, rpc.State{Exited: true}); err != ErrNotFound {
+		t.Errorf("Want done of completed pipeline rejected without a key, got %v", err)
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	q := New()
+	id := q.Push(&rpc.Pipeline{}, nil)
+	ctx := context.Background()
+	q.Next(ctx, rpc.NoFilter)
+
+	q.Cancel(id)
+	if err := q.Wait(ctx, id); err != ErrCancelled {
+		t.Errorf("Want wait to return cancelled, got %v", err)
+	}
+	if err := q.Update(ctx, id, rpc.State{}); err != ErrCancelled {
+		t.Errorf("Want update of cancelled pipeline rejected, got %v", err)
+	}
+
+	pending := q.Push(&rpc.Pipeline{}, nil)
+	q.Cancel(pending)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if p, _ := q.Next(ctx, rpc.NoFilter); p != nil {
+		t.Errorf("Want cancelled pipeline removed from the queue")
+	}
+}
+
+func TestQueueLease(t *testing.T) {
+	q := New(WithLease(100 * time.Millisecond))
+	id := q.Push(&rpc.Pipeline{}, nil)
+	ctx := context.Background()
+	q.Next(ctx, rpc.NoFilter)
+
+	for i := 0; i < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if err := q.Extend(ctx, id); err != nil {
+			t.Fatalf("Want lease extended, got %v", err)
+		}
+	}
+
+	if err := q.Wait(ctx, id); err != ErrLeaseExpired {
+		t.Errorf("Want wait to return lease expired, got %v", err)
+	}
+	p, err := q.Next(ctx, rpc.NoFilter)
+	if err != nil || p.ID != id {
+		t.Errorf("Want expired pipeline returned to the queue")
+	}
+}
+
+func TestQueueRetention(t *testing.T) {
+	q := New(WithRetention(10 * time.Millisecond))
+	done := q.Push(&rpc.Pipeline{}, nil)
+	ctx := context.Background()
+	q.Next(ctx, rpc.NoFilter)
+	q.Done(ctx, done, rpc.State{Exited: true})
+	cancelled := q.Push(&rpc.Pipeline{}, nil)
+	q.Cancel(cancelled)
+
+	if _, err := q.Info(done); err != nil {
+		t.Errorf("Want completed pipeline kept for the retention period, got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := q.Info(done); err != ErrNotFound {
+		t.Errorf("Want completed pipeline removed after the retention period, got %v", err)
+	}
+	if _, err := q.Info(cancelled); err != ErrNotFound {
+		t.Errorf("Want cancelled pipeline removed after the retention period, got %v", err)
+	}
+}
+
+func TestQueueLeaseOwner(t *testing.T) {
+	q := New(WithLease(10 * time.Millisecond))
+	id := q.Push(&rpc.Pipeline{}, nil)
+	stale := rpc.WithAgent(context.Background(), &rpc.Agent{ID: "agent-1"})
+	q.Next(stale, rpc.NoFilter)
+
+	if err := q.Wait(stale, id); err != ErrLeaseExpired {
+		t.Fatalf("Want lease expired, got %v", err)
+	}
+	owner := rpc.WithAgent(context.Background(), &rpc.Agent{ID: "agent-2"})
+	q.Next(owner, rpc.NoFilter)
+
+	if err := q.Update(stale, id, rpc.State{Proc: "build", ExitCode: 1}); err != ErrNotLeased {
+		t.Errorf("Want update from the expired lease holder rejected, got %v", err)
+	}
+	if err := q.Done(stale, id, rpc.State{Exited: true}); err != ErrNotLeased {
+		t.Errorf("Want done from the expired lease holder rejected, got %v", err)
+	}
+	if err := q.Update(owner, id, rpc.State{Proc: "build"}); err != nil {
+		t.Errorf("Want update from the lease holder accepted, got %v", err)
+	}
+	info, _ := q.Info(id)
+	if info.Status != StatusRunning || len(info.Procs) != 1 || info.Procs[0].ExitCode != 0 {
+		t.Errorf("Want pipeline state owned by the lease holder, got %+v", info)
+	}
+}
+
+func TestQueueFilterExpr(t *testing.T) {
+	q := New()
+	q.Push(&rpc.Pipeline{}, nil)
+	_, err := q.Next(context.Background(), rpc.Filter{Expr: "platform == 'linux/amd64'"})
+	if err != ErrFilterExpr {
+		t.Errorf("Want filter expression rejected, got %v", err)
+	}
+}
+
+func TestDiskSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q := New(WithSink(NewDiskSink(dir)))
+	id := q.Push(&rpc.Pipeline{}, nil)
+	ctx := context.Background()
+	q.Next(ctx, rpc.NoFilter)
+
+	q.Log(ctx, id, &rpc.Line{Proc: "build", Out: "go build\n"})
+	q.Log(ctx, id, &rpc.Line{Proc: "build", Out: "go test\n"})
+	q.Upload(ctx, id, &rpc.File{Proc: "build", Name: "../coverage.out", Data: []byte("mode: set")})
+
+	out, _ := ioutil.ReadFile(filepath.Join(dir, id, "build.log"))
+	if string(out) != "go build\ngo test\n" {
+		t.Errorf("Want logs appended, got %q", out)
+	}
+	out, _ = ioutil.ReadFile(filepath.Join(dir, id, "build", ".._coverage.out"))
+	if string(out) != "mode: set" {
+		t.Errorf("Want artifact written inside the sink directory, got %q", out)
+	}
+}
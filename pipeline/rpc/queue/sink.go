@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cncd/pipeline/pipeline/rpc"
+)
+
+// Sink stores the pipeline logs and artifacts.
+type Sink interface {
+	// Log writes the log entry.
+	Log(id string, line *rpc.Line) error
+	// Upload writes the artifact.
+	Upload(id string, file *rpc.File) error
+}
+
+type diskSink struct {
+	sync.Mutex
+	dir string
+}
+
+// NewDiskSink returns a sink that writes logs and artifacts to the
+// directory. The logs of each step are appended to <id>/<proc>.log and
+// the artifacts are written to <id>/<proc>/<name>.
+func NewDiskSink(dir string) Sink {
+	return &diskSink{dir: dir}
+}
+
+func (s *diskSink) Log(id string, line *rpc.Line) error {
+	s.Lock()
+	defer s.Unlock()
+
+	path := filepath.Join(s.dir, clean(id), clean(line.Proc)+".log")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(line.Out)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s *diskSink) Upload(id string, file *rpc.File) error {
+	path := filepath.Join(s.dir, clean(id), clean(file.Proc), clean(file.Name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFile(path, file.Data)
+}
+
+// helper function writes the file atomically.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// helper function returns the name with path separators removed, so
+// the name cannot escape the sink directory.
+func clean(name string) string {
+	name = strings.Replace(name, "/", "_", -1)
+	name = strings.Replace(name, `\`, "_", -1)
+	if name == "" || name == "." || name == ".." {
+		name = "_"
+	}
+	return name
+}
@@ -14,30 +14,86 @@ import (
 // errNoSuchMethod is returned when the name rpc method does not exist.
 var errNoSuchMethod = errors.New("No such rpc method")
 
-// noContext is an empty context used when no context is required.
-var noContext = context.Background()
-
 // Server represents an rpc server.
 type Server struct {
-	peer Peer
+	peer  Peer
+	auth  Authenticator
+	certs bool
+}
+
+// ServerOption configures a server option.
+type ServerOption func(*Server)
+
+// WithAuthenticator configures the server to authenticate agents using
+// the bearer token sent when the connection is opened. The agent
+// identity is added to the context of every request.
+func WithAuthenticator(auth Authenticator) ServerOption {
+	return func(s *Server) {
+		s.auth = auth
+	}
+}
+
+// WithClientCertificates configures the server to authenticate agents
+// using the verified tls client certificate. The certificate subject is
+// used as the agent identity. If the server also authenticates bearer
+// tokens, agents must present both, and the token and certificate must
+// identify the same agent.
+func WithClientCertificates() ServerOption {
+	return func(s *Server) {
+		s.certs = true
+	}
 }
 
 // NewServer returns an rpc Server.
-func NewServer(peer Peer) *Server {
-	return &Server{peer}
+func NewServer(peer Peer, opts ...ServerOption) *Server {
+	s := &Server{peer: peer}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // ServeHTTP implements an http.Handler that answers rpc requests.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	token := Bearer(r.Header.Get("Authorization"))
+	if s.auth != nil {
+		agent, err := s.auth.Authenticate(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		ctx = WithAgent(ctx, agent)
+	}
+	if s.certs {
+		agent, err := AgentFromTLS(r.TLS)
+		if err == nil {
+			ctx, err = withVerifiedAgent(ctx, agent)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
 	upgrader := websocket.Upgrader{}
 	c, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
 	}
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	conn := jsonrpc2.NewConn(ctx,
 		websocketrpc.NewObjectStream(c),
-		jsonrpc2.HandlerWithError(s.router),
+		jsonrpc2.HandlerWithError(func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+			// the token is checked again with every request, so that
+			// the connection is not usable once the token expires.
+			if s.auth != nil {
+				if _, err := s.auth.Authenticate(token); err != nil {
+					return nil, err
+				}
+			}
+			return s.router(ctx, conn, req)
+		}),
 	)
 	defer func() {
 		cancel()
@@ -60,11 +116,11 @@ func (s *Server) router(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.
 	case methodExtend:
 		return s.extend(ctx, req)
 	case methodUpdate:
-		return s.update(req)
+		return s.update(ctx, req)
 	case methodLog:
-		return s.log(req)
+		return s.log(ctx, req)
 	case methodUpload:
-		return s.upload(req)
+		return s.upload(ctx, req)
 	default:
 		return nil, errNoSuchMethod
 	}
@@ -108,6 +164,9 @@ func (s *Server) done(ctx context.Context, req *jsonrpc2.Request) (interface{},
 	if err := json.Unmarshal([]byte(*req.Params), in); err != nil {
 		return nil, err
 	}
+	if in.Key != "" {
+		ctx = WithIdempotencyKey(ctx, in.Key)
+	}
 	return nil, s.peer.Done(ctx, in.ID, in.State)
 }
 
@@ -124,28 +183,37 @@ func (s *Server) extend(ctx context.Context, req *jsonrpc2.Request) (interface{}
 
 // update unmarshals the rpc request parameters and invokes the peer.Update
 // procedure. The results are retuned and written to the rpc response.
-func (s *Server) update(req *jsonrpc2.Request) (interface{}, error) {
+func (s *Server) update(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
 	in := new(updateReq)
 	if err := json.Unmarshal([]byte(*req.Params), in); err != nil {
 		return nil, err
 	}
-	return nil, s.peer.Update(noContext, in.ID, in.State)
+	if in.Key != "" {
+		ctx = WithIdempotencyKey(ctx, in.Key)
+	}
+	return nil, s.peer.Update(ctx, in.ID, in.State)
 }
 
 // log unmarshals the rpc request parameters and invokes the peer.Log
 // procedure. The results are retuned and written to the rpc response.
-func (s *Server) log(req *jsonrpc2.Request) (interface{}, error) {
+func (s *Server) log(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
 	in := new(logReq)
 	if err := json.Unmarshal([]byte(*req.Params), in); err != nil {
 		return nil, err
 	}
-	return nil, s.peer.Log(noContext, in.ID, in.Line)
+	if in.Key != "" {
+		ctx = WithIdempotencyKey(ctx, in.Key)
+	}
+	return nil, s.peer.Log(ctx, in.ID, in.Line)
 }
 
-func (s *Server) upload(req *jsonrpc2.Request) (interface{}, error) {
+func (s *Server) upload(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
 	in := new(uploadReq)
 	if err := json.Unmarshal([]byte(*req.Params), in); err != nil {
 		return nil, err
 	}
-	return nil, s.peer.Upload(noContext, in.ID, in.File)
+	if in.Key != "" {
+		ctx = WithIdempotencyKey(ctx, in.Key)
+	}
+	return nil, s.peer.Upload(ctx, in.ID, in.File)
 }
@@ -0,0 +1,176 @@
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/cncd/pipeline/pipeline/rpc/proto"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Temporary returns an error that signals the peer operation failed for a
+// reason that is expected to resolve, such as a database outage. The grpc
+// server reports temporary errors with a code the client retries.
+func Temporary(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &temporaryError{err}
+}
+
+type temporaryError struct {
+	err error
+}
+
+func (e *temporaryError) Error() string   { return e.err.Error() }
+func (e *temporaryError) Temporary() bool { return true }
+
+type server struct {
+	peer Peer
+}
+
+// NewGrpcServer returns a new grpc server that answers requests using
+// the given peer.
+func NewGrpcServer(peer Peer) proto.DroneServer {
+	return &server{peer}
+}
+
+func (s *server) Next(ctx context.Context, req *proto.NextRequest) (*proto.NextReply, error) {
+	filter := Filter{
+		Labels: req.GetFilter().GetLabels(),
+		Expr:   req.GetFilter().GetExpr(),
+	}
+	work, err := s.peer.Next(ctx, filter)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	res := new(proto.NextReply)
+	if work == nil {
+		return res, nil
+	}
+	res.Pipeline = new(proto.Pipeline)
+	res.Pipeline.Id = work.ID
+	res.Pipeline.Timeout = work.Timeout
+	res.Pipeline.TraceId = work.TraceID
+	res.Pipeline.Payload, err = json.Marshal(work.Config)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return res, nil
+}
+
+func (s *server) Init(ctx context.Context, req *proto.InitRequest) (*proto.Empty, error) {
+	err := s.peer.Init(ctx, req.GetId(), fromProtoState(req.GetState()))
+	return new(proto.Empty), grpcError(err)
+}
+
+func (s *server) Wait(ctx context.Context, req *proto.WaitRequest) (*proto.Empty, error) {
+	err := s.peer.Wait(ctx, req.GetId())
+	return new(proto.Empty), grpcError(err)
+}
+
+func (s *server) Done(ctx context.Context, req *proto.DoneRequest) (*proto.Empty, error) {
+	ctx = incomingKey(ctx)
+	err := s.peer.Done(ctx, req.GetId(), fromProtoState(req.GetState()))
+	return new(proto.Empty), grpcError(err)
+}
+
+func (s *server) Extend(ctx context.Context, req *proto.ExtendRequest) (*proto.Empty, error) {
+	err := s.peer.Extend(ctx, req.GetId())
+	return new(proto.Empty), grpcError(err)
+}
+
+func (s *server) Update(ctx context.Context, req *proto.UpdateRequest) (*proto.Empty, error) {
+	ctx = incomingKey(ctx)
+	err := s.peer.Update(ctx, req.GetId(), fromProtoState(req.GetState()))
+	return new(proto.Empty), grpcError(err)
+}
+
+func (s *server) Upload(ctx context.Context, req *proto.UploadRequest) (*proto.Empty, error) {
+	ctx = incomingKey(ctx)
+	file := &File{
+		Name: req.GetFile().GetName(),
+		Proc: req.GetFile().GetProc(),
+		Mime: req.GetFile().GetMime(),
+		Time: req.GetFile().GetTime(),
+		Size: int(req.GetFile().GetSize()),
+		Data: req.GetFile().GetData(),
+		Meta: req.GetFile().GetMeta(),
+	}
+	err := s.peer.Upload(ctx, req.GetId(), file)
+	return new(proto.Empty), grpcError(err)
+}
+
+func (s *server) Log(ctx context.Context, req *proto.LogRequest) (*proto.Empty, error) {
+	ctx = incomingKey(ctx)
+	line := &Line{
+		Proc: req.GetLine().GetProc(),
+		Time: req.GetLine().GetTime(),
+		Pos:  int(req.GetLine().GetPos()),
+		Out:  req.GetLine().GetOut(),
+	}
+	err := s.peer.Log(ctx, req.GetId(), line)
+	return new(proto.Empty), grpcError(err)
+}
+
+type healthServer struct {
+	health Health
+}
+
+// NewGrpcHealthServer returns a new grpc health server that reports the
+// serving status using the given health check.
+func NewGrpcHealthServer(health Health) proto.HealthServer {
+	return &healthServer{health}
+}
+
+func (s *healthServer) Check(ctx context.Context, req *proto.HealthCheckRequest) (*proto.HealthCheckResponse, error) {
+	res := new(proto.HealthCheckResponse)
+	ok, err := s.health.Check(ctx)
+	switch {
+	case err != nil || !ok:
+		res.Status = proto.HealthCheckResponse_NOT_SERVING
+	default:
+		res.Status = proto.HealthCheckResponse_SERVING
+	}
+	return res, nil
+}
+
+// helper function converts the proto state.
+func fromProtoState(state *proto.State) State {
+	return State{
+		Proc:     state.GetName(),
+		Exited:   state.GetExited(),
+		ExitCode: int(state.GetExitCode()),
+		Started:  state.GetStarted(),
+		Finished: state.GetFinished(),
+		Error:    state.GetError(),
+	}
+}
+
+// helper function maps the peer error to a grpc error. Temporary errors
+// and deadlines are mapped to codes the client retries, while all other
+// errors, including the error returned by Wait when the pipeline is
+// cancelled, are mapped to codes the client returns to the caller.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if code := grpc.Code(err); code != codes.Unknown {
+		// the error already carries a grpc code.
+		return err
+	}
+	switch err {
+	case context.DeadlineExceeded:
+		return grpc.Errorf(codes.DeadlineExceeded, "%s", err)
+	case context.Canceled:
+		return grpc.Errorf(codes.Canceled, "%s", err)
+	}
+	if temp, ok := err.(interface {
+		Temporary() bool
+	}); ok && temp.Temporary() {
+		return grpc.Errorf(codes.Unavailable, "%s", err)
+	}
+	return grpc.Errorf(codes.Unknown, "%s", err)
+}
@@ -0,0 +1,159 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/rpc/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestGrpcServer(t *testing.T) {
+	peer := &mockPeer{
+		work: &Pipeline{
+			ID:      "1",
+			Timeout: 60,
+			TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			Config:  &backend.Config{Stages: []*backend.Stage{{Name: "build"}}},
+		},
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	proto.RegisterDroneServer(srv, NewGrpcServer(peer))
+	go srv.Serve(listener)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := NewGrpcClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	work, err := client.Next(ctx, Filter{Labels: map[string]string{"platform": "linux/amd64"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work.ID != "1" || work.Timeout != 60 || work.TraceID != peer.work.TraceID {
+		t.Errorf("Want pipeline received, got %+v", work)
+	}
+	if len(work.Config.Stages) != 1 || work.Config.Stages[0].Name != "build" {
+		t.Errorf("Want pipeline configuration received")
+	}
+	if peer.filter.Labels["platform"] != "linux/amd64" {
+		t.Errorf("Want filter labels sent to the peer")
+	}
+
+	if err := client.Update(ctx, "1", State{Proc: "build", ExitCode: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if peer.state.Proc != "build" || peer.state.ExitCode != 1 {
+		t.Errorf("Want step state sent to the peer, got %+v", peer.state)
+	}
+
+	if err := client.Wait(ctx, "1"); grpc.Code(err) != codes.Unknown {
+		t.Errorf("Want cancelled wait returned to the caller, got %v", err)
+	}
+}
+
+func TestGrpcError(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{Temporary(errors.New("database unavailable")), codes.Unavailable},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{context.Canceled, codes.Canceled},
+		{errors.New("pipeline cancelled"), codes.Unknown},
+		{grpc.Errorf(codes.NotFound, "not found"), codes.NotFound},
+	}
+	for _, test := range tests {
+		if got := grpc.Code(grpcError(test.err)); got != test.code {
+			t.Errorf("Want code %s for error %q, got %s", test.code, test.err, got)
+		}
+	}
+	if grpcError(nil) != nil {
+		t.Errorf("Want nil error mapped to nil")
+	}
+}
+
+func TestGrpcHealthServer(t *testing.T) {
+	tests := []struct {
+		ok     bool
+		err    error
+		status proto.HealthCheckResponse_ServingStatus
+	}{
+		{true, nil, proto.HealthCheckResponse_SERVING},
+		{false, nil, proto.HealthCheckResponse_NOT_SERVING},
+		{true, errors.New("database unavailable"), proto.HealthCheckResponse_NOT_SERVING},
+	}
+	for _, test := range tests {
+		server := NewGrpcHealthServer(mockHealth{test.ok, test.err})
+		res, _ := server.Check(context.Background(), new(proto.HealthCheckRequest))
+		if res.Status != test.status {
+			t.Errorf("Want status %s, got %s", test.status, res.Status)
+		}
+	}
+}
+
+type mockHealth struct {
+	ok  bool
+	err error
+}
+
+func (h mockHealth) Check(context.Context) (bool, error) {
+	return h.ok, h.err
+}
+
+type mockPeer struct {
+	work   *Pipeline
+	filter Filter
+	state  State
+}
+
+func (p *mockPeer) Next(c context.Context, f Filter) (*Pipeline, error) {
+	p.filter = f
+	return p.work, nil
+}
+
+func (p *mockPeer) Wait(c context.Context, id string) error {
+	return errors.New("pipeline cancelled")
+}
+
+func (p *mockPeer) Init(c context.Context, id string, state State) error {
+	return nil
+}
+
+func (p *mockPeer) Done(c context.Context, id string, state State) error {
+	return nil
+}
+
+func (p *mockPeer) Extend(c context.Context, id string) error {
+	return nil
+}
+
+func (p *mockPeer) Update(c context.Context, id string, state State) error {
+	p.state = state
+	return nil
+}
+
+func (p *mockPeer) Upload(c context.Context, id string, file *File) error {
+	return nil
+}
+
+func (p *mockPeer) Log(c context.Context, id string, line *Line) error {
+	return nil
+}
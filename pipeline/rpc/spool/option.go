@@ -0,0 +1,14 @@
+package spool
+
+import "time"
+
+// Option configures a spool option.
+type Option func(*Spool)
+
+// WithBackoff configures the time the spool waits before retrying a
+// call that failed to reach the server.
+func WithBackoff(d time.Duration) Option {
+	return func(s *Spool) {
+		s.backoff = d
+	}
+}
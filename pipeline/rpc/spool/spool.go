@@ -0,0 +1,356 @@
+// Package spool provides a durable rpc.Peer that writes pipeline
+// updates, logs, uploads and done calls to a write-ahead log on disk,
+// and delivers them to the server in order for each pipeline, so that
+// they are not lost when the server connection drops or the agent
+// crashes.
+package spool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/rpc"
+)
+
+const (
+	methodUpdate = "update"
+	methodLog    = "log"
+	methodUpload = "upload"
+	methodDone   = "done"
+)
+
+// errInvalidCall is returned when the spooled call cannot be made.
+var errInvalidCall = errors.New("spool: invalid call")
+
+// defaultBackoff is the time the spool waits before retrying a call
+// that failed to reach the server.
+const defaultBackoff = 5 * time.Second
+
+// record defines a spooled call.
+type record struct {
+	Key    string     `json:"key"`
+	Method string     `json:"method"`
+	ID     string     `json:"id"`
+	State  *rpc.State `json:"state,omitempty"`
+	Line   *rpc.Line  `json:"line,omitempty"`
+	File   *rpc.File  `json:"file,omitempty"`
+}
+
+// Spool is an rpc.Peer that spools Update, Log, Upload and Done calls
+// to disk before they are delivered to the server. The calls return as
+// soon as the call is written to disk. Each call is sent with an
+// idempotency key, so that the server can drop calls delivered more
+// than once. Other calls are made directly.
+type Spool struct {
+	peer    rpc.Peer
+	backoff time.Duration
+
+	mu      sync.Mutex
+	wal     *wal
+	prefix  string
+	seq     int64
+	err     error
+	changed chan struct{}
+}
+
+// entry defines a spooled call that is not yet known to be delivered.
+type entry struct {
+	offset    int64
+	n         int64
+	id        string
+	delivered bool
+}
+
+// New returns a spool that writes to the directory and delivers calls
+// to the peer. Calls left in the directory by a previous process are
+// delivered before new calls.
+func New(peer rpc.Peer, dir string, opts ...Option) (*Spool, error) {
+	wal, err := openWAL(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, 8)
+	if _, err := rand.Read(prefix); err != nil {
+		wal.close()
+		return nil, err
+	}
+	s := &Spool{
+		peer:    peer,
+		backoff: defaultBackoff,
+		wal:     wal,
+		prefix:  hex.EncodeToString(prefix),
+		changed: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Next returns the next pipeline in the queue.
+func (s *Spool) Next(c context.Context, f rpc.Filter) (*rpc.Pipeline, error) {
+	return s.peer.Next(c, f)
+}
+
+// Wait blocks until the pipeline is complete.
+func (s *Spool) Wait(c context.Context, id string) error {
+	return s.peer.Wait(c, id)
+}
+
+// Init signals the pipeline is initialized.
+func (s *Spool) Init(c context.Context, id string, state rpc.State) error {
+	return s.peer.Init(c, id, state)
+}
+
+// Extend extends the pipeline deadline.
+func (s *Spool) Extend(c context.Context, id string) error {
+	return s.peer.Extend(c, id)
+}
+
+// Done spools the pipeline done call.
+func (s *Spool) Done(c context.Context, id string, state rpc.State) error {
+	return s.append(&record{Method: methodDone, ID: id, State: &state}, true)
+}
+
+// Update spools the pipeline state update.
+func (s *Spool) Update(c context.Context, id string, state rpc.State) error {
+	return s.append(&record{Method: methodUpdate, ID: id, State: &state}, false)
+}
+
+// Upload spools the pipeline artifact.
+func (s *Spool) Upload(c context.Context, id string, file *rpc.File) error {
+	return s.append(&record{Method: methodUpload, ID: id, File: file}, true)
+}
+
+// Log spools the pipeline log entry.
+func (s *Spool) Log(c context.Context, id string, line *rpc.Line) error {
+	return s.append(&record{Method: methodLog, ID: id, Line: line}, false)
+}
+
+// Run delivers the spooled calls to the peer until the context is
+// cancelled. Calls for the same pipeline are delivered in order, and a
+// call that fails to reach the server is retried without holding back
+// the calls of other pipelines. Calls rejected by the server are
+// dropped. If the spool cannot be read, Run returns the error and the
+// spool stops accepting calls.
+func (s *Spool) Run(ctx context.Context) error {
+	var pending []*entry
+	for {
+		var changed <-chan struct{}
+		var err error
+		pending, changed, err = s.scan(pending)
+		if err != nil {
+			return s.fail(err)
+		}
+		if len(pending) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-changed:
+			}
+			continue
+		}
+
+		retry := s.deliverAll(ctx, pending)
+
+		// the spool is advanced past the calls delivered in order. Calls
+		// delivered after a call that is retried are delivered again,
+		// with the same idempotency key, if the agent restarts.
+		var i int
+		var n int64
+		for ; i < len(pending) && pending[i].delivered; i++ {
+			n += pending[i].n
+		}
+		if n != 0 {
+			if err := s.advance(n); err != nil {
+				return s.fail(err)
+			}
+		}
+		pending = pending[i:]
+
+		if retry {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.backoff):
+			}
+		}
+	}
+}
+
+// deliverAll makes the pending calls, skipping the calls of a pipeline
+// after a call of that pipeline fails to reach the server. It returns
+// true if a call must be retried.
+func (s *Spool) deliverAll(ctx context.Context, pending []*entry) bool {
+	var retry bool
+	blocked := map[string]bool{}
+	for _, e := range pending {
+		if ctx.Err() != nil {
+			return true
+		}
+		if e.delivered || blocked[e.id] {
+			continue
+		}
+		rec, err := s.read(e)
+		if err != nil {
+			log.Printf("spool: dropping unreadable call: %s", err)
+			e.delivered = true
+			continue
+		}
+		if err := s.deliver(ctx, rec); err != nil {
+			if !rpc.IsRejected(err) && err != errInvalidCall {
+				log.Printf("spool: cannot deliver %s: %s: %s", rec.Method, rec.ID, err)
+				blocked[e.id] = true
+				retry = true
+				continue
+			}
+			log.Printf("spool: dropping %s: %s: %s", rec.Method, rec.ID, err)
+		}
+		e.delivered = true
+	}
+	return retry
+}
+
+// Flush blocks until every spooled call is delivered, the context is
+// cancelled, or the spool stops delivering calls.
+func (s *Spool) Flush(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		empty, changed, err := s.wal.empty(), s.changed, s.err
+		s.mu.Unlock()
+		if empty {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Close closes the spool. Calls that are not delivered are kept on
+// disk and delivered when the spool is re-opened.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wal.close()
+}
+
+// append writes the call to the spool with a new idempotency key.
+func (s *Spool) append(rec *record, sync bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+
+	s.seq++
+	rec.Key = fmt.Sprintf("%s-%d", s.prefix, s.seq)
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := s.wal.append(data, sync); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// scan adds the calls spooled after the pending calls. If there are no
+// pending calls, the returned channel is closed when a call is spooled.
+func (s *Spool) scan(pending []*entry) ([]*entry, <-chan struct{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset := s.wal.head
+	for _, e := range pending {
+		offset += e.n
+	}
+	for {
+		data, n, err := s.wal.read(offset)
+		if err != nil {
+			return pending, s.changed, err
+		}
+		if data == nil {
+			return pending, s.changed, nil
+		}
+		// the pipeline id is only used to order the calls, so a call
+		// that cannot be decoded is dropped when it is delivered.
+		rec := new(record)
+		json.Unmarshal(data, rec)
+		pending = append(pending, &entry{offset: offset, n: n, id: rec.ID})
+		offset += n
+	}
+}
+
+// read returns the spooled call.
+func (s *Spool) read(e *entry) (*record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, _, err := s.wal.read(e.offset)
+	if err != nil {
+		return nil, err
+	}
+	rec := new(record)
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// fail stops the spool from accepting calls, and returns the error.
+func (s *Spool) fail(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = fmt.Errorf("spool: cannot deliver calls: %s", err)
+	s.notify()
+	return s.err
+}
+
+// advance marks the call as delivered.
+func (s *Spool) advance(n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.wal.advance(n); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// deliver makes the spooled call.
+func (s *Spool) deliver(ctx context.Context, rec *record) error {
+	ctx = rpc.WithIdempotencyKey(ctx, rec.Key)
+	switch {
+	case rec.Method == methodUpdate && rec.State != nil:
+		return s.peer.Update(ctx, rec.ID, *rec.State)
+	case rec.Method == methodLog:
+		return s.peer.Log(ctx, rec.ID, rec.Line)
+	case rec.Method == methodUpload:
+		return s.peer.Upload(ctx, rec.ID, rec.File)
+	case rec.Method == methodDone && rec.State != nil:
+		return s.peer.Done(ctx, rec.ID, *rec.State)
+	default:
+		return errInvalidCall
+	}
+}
+
+// notify wakes up the goroutines waiting for the spool to change. The
+// lock must be held.
+func (s *Spool) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
@@ -0,0 +1,261 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestSpoolDeliver(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	peer := &mockPeer{failures: 2}
+	s, err := New(peer, dir, WithBackoff(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	s.Update(ctx, "1", rpc.State{Proc: "build"})
+	s.Log(ctx, "1", &rpc.Line{Proc: "build", Out: "hello"})
+	s.Upload(ctx, "1", &rpc.File{Name: "report.xml", Data: []byte("<xml/>")})
+	s.Done(ctx, "1", rpc.State{Exited: true})
+
+	if err := flush(s); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := peer.methods(), []string{"update", "log", "upload", "done"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want calls delivered in order %v, got %v", want, got)
+	}
+	if keys := peer.uniqueKeys(); keys != 4 {
+		t.Errorf("Want a distinct idempotency key for each call, got %d", keys)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "spool.log")); info.Size() != 0 {
+		t.Errorf("Want spool truncated once every call is delivered")
+	}
+}
+
+func TestSpoolReplay(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := New(new(mockPeer), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	s.Log(ctx, "1", &rpc.Line{Out: "hello"})
+	s.Done(ctx, "1", rpc.State{Exited: true})
+	s.Close()
+
+	// simulate a crash while the last call was being written.
+	f, _ := os.OpenFile(filepath.Join(dir, "spool.log"), os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"key":"partial","method":"log"`)
+	f.Close()
+
+	peer := new(mockPeer)
+	s, err = New(peer, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.Run(ctx)
+
+	if err := flush(s); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := peer.methods(), []string{"log", "done"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want spooled calls replayed %v, got %v", want, got)
+	}
+}
+
+func TestSpoolRejected(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	peer := &mockPeer{reject: "update"}
+	s, err := New(peer, dir, WithBackoff(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	s.Update(ctx, "1", rpc.State{})
+	s.Done(ctx, "1", rpc.State{Exited: true})
+
+	if err := flush(s); err != nil {
+		t.Errorf("Want call rejected by the server dropped, got %v", err)
+	}
+	if got, want := peer.methods(), []string{"done"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want calls %v, got %v", want, got)
+	}
+}
+
+func TestSpoolPipelineOrder(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	peer := &mockPeer{unreachable: "1"}
+	s, err := New(peer, dir, WithBackoff(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	s.Update(ctx, "1", rpc.State{})
+	s.Log(ctx, "2", &rpc.Line{Out: "hello"})
+	s.Done(ctx, "1", rpc.State{Exited: true})
+	s.Done(ctx, "2", rpc.State{Exited: true})
+
+	if err := flush(s); err == nil {
+		t.Errorf("Want calls that fail to reach the server kept in the spool")
+	}
+	if got, want := peer.methods(), []string{"log", "done"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want calls of other pipelines delivered %v, got %v", want, got)
+	}
+
+	// the calls of the pipeline are delivered in order once the server
+	// accepts them.
+	peer.Lock()
+	peer.unreachable = ""
+	peer.Unlock()
+	if err := flush(s); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := peer.methods(), []string{"log", "done", "update", "done"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want calls delivered %v, got %v", want, got)
+	}
+}
+
+func TestSpoolStopped(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := New(new(mockPeer), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	s.Log(ctx, "1", &rpc.Line{Out: "hello"})
+
+	// simulate an error reading the spool.
+	s.wal.log.Close()
+	if err := s.Run(ctx); err == nil {
+		t.Fatalf("Want error when the spool cannot be read")
+	}
+	if err := s.Done(ctx, "1", rpc.State{Exited: true}); err == nil {
+		t.Errorf("Want calls refused once the spool stops delivering calls")
+	}
+	if err := flush(s); err == nil || err == context.DeadlineExceeded {
+		t.Errorf("Want flush to return the spool error, got %v", err)
+	}
+}
+
+//
+// mock peer used to test the spool.
+//
+
+type mockPeer struct {
+	rpc.Peer
+
+	sync.Mutex
+	failures    int
+	reject      string
+	unreachable string
+	calls       []string
+	keys        []string
+}
+
+func (p *mockPeer) call(c context.Context, id, method string) error {
+	p.Lock()
+	defer p.Unlock()
+	if id == p.unreachable {
+		return errors.New("internal error")
+	}
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("connection closed")
+	}
+	if method == p.reject {
+		return &jsonrpc2.Error{Message: "pipeline not found"}
+	}
+	key, _ := rpc.IdempotencyKeyFromContext(c)
+	p.calls = append(p.calls, method)
+	p.keys = append(p.keys, key)
+	return nil
+}
+
+func (p *mockPeer) Update(c context.Context, id string, state rpc.State) error {
+	return p.call(c, id, "update")
+}
+
+func (p *mockPeer) Log(c context.Context, id string, line *rpc.Line) error {
+	return p.call(c, id, "log")
+}
+
+func (p *mockPeer) Upload(c context.Context, id string, file *rpc.File) error {
+	return p.call(c, id, "upload")
+}
+
+func (p *mockPeer) Done(c context.Context, id string, state rpc.State) error {
+	return p.call(c, id, "done")
+}
+
+func (p *mockPeer) methods() []string {
+	p.Lock()
+	defer p.Unlock()
+	return p.calls
+}
+
+func (p *mockPeer) uniqueKeys() int {
+	p.Lock()
+	defer p.Unlock()
+	keys := map[string]struct{}{}
+	for _, key := range p.keys {
+		if key != "" {
+			keys[key] = struct{}{}
+		}
+	}
+	return len(keys)
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func flush(s *Spool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return s.Flush(ctx)
+}
@@ -0,0 +1,137 @@
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// wal is an append-only log of newline delimited records. The offset
+// of the first undelivered record is stored in a separate file, and
+// the log is truncated once every record is delivered.
+type wal struct {
+	log  *os.File
+	ack  *os.File
+	size int64 // offset after the last complete record
+	head int64 // offset of the first undelivered record
+}
+
+// openWAL opens the log in the directory, discarding the last record
+// if it was partially written when the previous process crashed.
+func openWAL(dir string) (*wal, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(filepath.Join(dir, "spool.log"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	ack, err := os.OpenFile(filepath.Join(dir, "spool.ack"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	w := &wal{log: log, ack: ack}
+
+	data, _ := ioutil.ReadAll(ack)
+	w.head, _ = strconv.ParseInt(string(bytes.TrimSpace(data)), 10, 64)
+
+	info, err := log.Stat()
+	if err != nil {
+		w.close()
+		return nil, err
+	}
+	if w.size, err = lastRecord(log, info.Size()); err != nil {
+		w.close()
+		return nil, err
+	}
+	if w.size != info.Size() {
+		if err := log.Truncate(w.size); err != nil {
+			w.close()
+			return nil, err
+		}
+	}
+	if w.head < 0 || w.head > w.size {
+		w.head = 0
+	}
+	return w, nil
+}
+
+// append writes the record to the end of the log.
+func (w *wal) append(data []byte, sync bool) error {
+	data = append(data, '\n')
+	n, err := w.log.WriteAt(data, w.size)
+	if err != nil {
+		// the partial record is overwritten by the next record.
+		return err
+	}
+	w.size += int64(n)
+	if sync {
+		return w.log.Sync()
+	}
+	return nil
+}
+
+// read returns the record at the offset and its length in the log, or
+// nil if there is no record after the offset.
+func (w *wal) read(offset int64) ([]byte, int64, error) {
+	if offset >= w.size {
+		return nil, 0, nil
+	}
+	r := bufio.NewReader(io.NewSectionReader(w.log, offset, w.size-offset))
+	data, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, 0, err
+	}
+	return data[:len(data)-1], int64(len(data)), nil
+}
+
+// advance marks the first n bytes of undelivered records as delivered.
+func (w *wal) advance(n int64) error {
+	w.head += n
+	if w.head >= w.size {
+		if err := w.log.Truncate(0); err != nil {
+			return err
+		}
+		w.head, w.size = 0, 0
+	}
+	// the offset is padded to a fixed width so that it overwrites the
+	// previous offset in place.
+	_, err := w.ack.WriteAt([]byte(fmt.Sprintf("%-20d\n", w.head)), 0)
+	return err
+}
+
+// empty returns true if every record is delivered.
+func (w *wal) empty() bool {
+	return w.head == w.size
+}
+
+func (w *wal) close() error {
+	w.ack.Close()
+	return w.log.Close()
+}
+
+// helper function returns the offset after the last complete record in
+// the first size bytes of the log.
+func lastRecord(log *os.File, size int64) (int64, error) {
+	var last, offset int64
+	r := bufio.NewReader(io.NewSectionReader(log, 0, size))
+	for {
+		line, err := r.ReadSlice('\n')
+		offset += int64(len(line))
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF:
+			return last, nil
+		case err != nil:
+			return 0, err
+		}
+		last = offset
+	}
+}
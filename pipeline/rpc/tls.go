@@ -0,0 +1,170 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNoClientCertificate is returned when the agent does not present a
+// verified client certificate.
+var ErrNoClientCertificate = errors.New("rpc: no verified client certificate")
+
+// Certificates loads the certificate authority bundle and the x509 key
+// pair used to establish mutual tls connections. The files are reloaded
+// on the next handshake after they change on disk, so certificates can
+// be rotated without restarting the process.
+type Certificates struct {
+	mu   sync.Mutex
+	ca   string
+	cert string
+	key  string
+
+	mod  map[string]fileVersion
+	pool *x509.CertPool
+	pair *tls.Certificate
+}
+
+type fileVersion struct {
+	size    int64
+	modtime time.Time
+}
+
+// NewCertificates returns a certificate loader for the certificate
+// authority bundle, and the certificate and key files. Each file is
+// optional, but the certificate and key must be provided together.
+func NewCertificates(ca, cert, key string) (*Certificates, error) {
+	if (cert == "") != (key == "") {
+		return nil, errors.New("rpc: certificate and key must be provided together")
+	}
+	c := &Certificates{
+		ca:   ca,
+		cert: cert,
+		key:  key,
+		mod:  map[string]fileVersion{},
+	}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ClientConfig returns a tls configuration, for agents connecting to
+// the server, from the current certificate files. The server name
+// overrides the host name used to verify the server certificate.
+func (c *Certificates) ClientConfig(serverName string) *tls.Config {
+	pool, pair := c.load()
+	config := &tls.Config{
+		ServerName: serverName,
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	if pair != nil {
+		config.Certificates = []tls.Certificate{*pair}
+	}
+	return config
+}
+
+// ServerConfig returns a tls configuration for the server. The current
+// certificate files are used for each handshake and, if a certificate
+// authority bundle is provided, agents must present a client
+// certificate signed by the certificate authority.
+func (c *Certificates) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			pool, pair := c.load()
+			if pair == nil {
+				return nil, errors.New("rpc: no server certificate")
+			}
+			config := &tls.Config{
+				Certificates: []tls.Certificate{*pair},
+				MinVersion:   tls.VersionTLS12,
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			if pool != nil {
+				config.ClientCAs = pool
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return config, nil
+		},
+	}
+}
+
+// load reloads the certificate files if they changed, and returns the
+// certificate pool and key pair. If the files cannot be reloaded, for
+// example because they are being replaced, the previous certificates
+// are returned.
+func (c *Certificates) load() (*x509.CertPool, *tls.Certificate) {
+	if err := c.reload(); err != nil {
+		log.Printf("rpc: error reloading certificates: %s", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pool, c.pair
+}
+
+func (c *Certificates) reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ca != "" && c.changed(c.ca) {
+		data, err := ioutil.ReadFile(c.ca)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("rpc: no certificates found in %s", c.ca)
+		}
+		c.pool = pool
+		c.commit(c.ca)
+	}
+	if c.cert != "" && (c.changed(c.cert) || c.changed(c.key)) {
+		pair, err := tls.LoadX509KeyPair(c.cert, c.key)
+		if err != nil {
+			return err
+		}
+		c.pair = &pair
+		c.commit(c.cert)
+		c.commit(c.key)
+	}
+	return nil
+}
+
+// helper function returns true if the file size or modification time
+// changed since the file was last loaded.
+func (c *Certificates) changed(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	return c.mod[path] != fileVersion{info.Size(), info.ModTime()}
+}
+
+// helper function records the version of the loaded file.
+func (c *Certificates) commit(path string) {
+	if info, err := os.Stat(path); err == nil {
+		c.mod[path] = fileVersion{info.Size(), info.ModTime()}
+	}
+}
+
+// AgentFromTLS returns the identity of the agent that presented a
+// verified client certificate. The certificate subject common name is
+// used as the agent identity.
+func AgentFromTLS(state *tls.ConnectionState) (*Agent, error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, ErrNoClientCertificate
+	}
+	subject := state.VerifiedChains[0][0].Subject
+	if subject.CommonName == "" {
+		return nil, ErrNoClientCertificate
+	}
+	return &Agent{ID: subject.CommonName}, nil
+}
@@ -0,0 +1,238 @@
+package rpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertificatesReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	ca.writeFiles(t, dir, "ca.pem", "", "")
+	ca.issue(t, "agent-1").writeFiles(t, dir, "", "cert.pem", "key.pem")
+
+	certs, err := NewCertificates(
+		filepath.Join(dir, "ca.pem"),
+		filepath.Join(dir, "cert.pem"),
+		filepath.Join(dir, "key.pem"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := commonName(t, certs.ClientConfig("")); got != "agent-1" {
+		t.Errorf("Want client certificate agent-1, got %s", got)
+	}
+
+	ca.issue(t, "agent-2").writeFiles(t, dir, "", "cert.pem", "key.pem")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "cert.pem"), later, later)
+	os.Chtimes(filepath.Join(dir, "key.pem"), later, later)
+
+	if got := commonName(t, certs.ClientConfig("")); got != "agent-2" {
+		t.Errorf("Want rotated client certificate agent-2, got %s", got)
+	}
+
+	os.Remove(filepath.Join(dir, "key.pem"))
+	if got := commonName(t, certs.ClientConfig("")); got != "agent-2" {
+		t.Errorf("Want previous certificate kept when reloading fails, got %s", got)
+	}
+}
+
+func TestCertificatesInvalid(t *testing.T) {
+	if _, err := NewCertificates("", "cert.pem", ""); err == nil {
+		t.Errorf("Want error when the certificate is provided without a key")
+	}
+	if _, err := NewCertificates("testdata/missing.pem", "", ""); err == nil {
+		t.Errorf("Want error when the certificate authority cannot be read")
+	}
+}
+
+func TestServerClientCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	ca.writeFiles(t, dir, "ca.pem", "", "")
+	ca.issue(t, "server").writeFiles(t, dir, "", "server.pem", "server.key")
+	ca.issue(t, "agent-1").writeFiles(t, dir, "", "agent.pem", "agent.key")
+
+	serverCerts, err := NewCertificates(
+		filepath.Join(dir, "ca.pem"),
+		filepath.Join(dir, "server.pem"),
+		filepath.Join(dir, "server.key"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(NewServer(new(mockPeer), WithClientCertificates()))
+	server.TLS = serverCerts.ServerConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	agentCerts, err := NewCertificates(
+		filepath.Join(dir, "ca.pem"),
+		filepath.Join(dir, "agent.pem"),
+		filepath.Join(dir, "agent.key"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: agentCerts.ClientConfig("localhost")},
+	}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusUnauthorized {
+		t.Errorf("Want agent with a client certificate authenticated")
+	}
+
+	endpoint := "wss://" + server.Listener.Addr().String()
+	ws, _ := NewClient(endpoint, WithRetryLimit(1), WithTLS(agentCerts, "localhost"))
+	if ws.connection() == nil {
+		t.Errorf("Want websocket client connected with the client certificate")
+	} else {
+		ws.Close()
+	}
+
+	anonymous, err := NewCertificates(filepath.Join(dir, "ca.pem"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{
+		Transport: &http.Transport{TLSClientConfig: anonymous.ClientConfig("localhost")},
+	}
+	if res, err := client.Get(server.URL); err == nil {
+		res.Body.Close()
+		t.Errorf("Want agent without a client certificate rejected")
+	}
+}
+
+func TestAgentFromTLS(t *testing.T) {
+	if _, err := AgentFromTLS(nil); err != ErrNoClientCertificate {
+		t.Errorf("Want error without a tls connection, got %v", err)
+	}
+	if _, err := AgentFromTLS(new(tls.ConnectionState)); err != ErrNoClientCertificate {
+		t.Errorf("Want error without a verified client certificate, got %v", err)
+	}
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "agent-1"}}
+	state := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	if agent, err := AgentFromTLS(state); err != nil || agent.ID != "agent-1" {
+		t.Errorf("Want certificate subject mapped to the agent identity, got %v", err)
+	}
+}
+
+//
+// test certificates.
+//
+
+type testCert struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCert {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return newTestCert(t, template, nil)
+}
+
+func (ca *testCert) issue(t *testing.T, name string) *testCert {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	return newTestCert(t, template, ca)
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, der: der, key: key}
+}
+
+func (c *testCert) writeFiles(t *testing.T, dir, ca, cert, key string) {
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})
+	for _, name := range []string{ca, cert} {
+		if name != "" {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), block, 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if key != "" {
+		der, err := x509.MarshalECPrivateKey(c.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		if err := ioutil.WriteFile(filepath.Join(dir, key), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// helper function returns the common name of the client certificate.
+func commonName(t *testing.T, config *tls.Config) string {
+	if len(config.Certificates) == 0 {
+		t.Fatalf("Want client certificate in the tls configuration")
+	}
+	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Subject.CommonName
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import "github.com/cncd/pipeline/pipeline/backend"
+
+// ServicePolicy defines how the runtime handles a detached step that
+// exits unsuccessfully before the pipeline completes.
+type ServicePolicy int
+
+const (
+	// ServiceWarn reports the exit state through the tracer and lets
+	// the pipeline continue.
+	ServiceWarn ServicePolicy = iota
+
+	// ServiceFail reports the exit state through the tracer and fails
+	// the pipeline. The steps of the running stage are killed, and
+	// subsequent steps only run if they are configured to run on
+	// failure.
+	ServiceFail
+)
+
+// monitor waits in the background for the detached step to exit, for
+// the lifetime of the pipeline.
+func (r *Runtime) monitor(proc *backend.Step) {
+	r.monitors.Add(1)
+	go func() {
+		defer r.monitors.Done()
+
+		state, err := r.engine.Wait(r.mctx, proc)
+		if err != nil {
+			return
+		}
+
+		r.mu.Lock()
+		ending := r.ending
+		r.mu.Unlock()
+		if ending {
+			// the step was stopped by the runtime.
+			return
+		}
+
+		reason := ""
+		if proc.AllowFailure {
+			reason = ReasonAllowed
+		}
+		r.transition(proc, exitState(state), state.ExitCode, reason)
+		if r.policy == ServiceFail && !proc.AllowFailure {
+			r.mu.Lock()
+			if r.svcerr == nil {
+				r.svcerr = exitError(proc, state)
+				if r.err == nil {
+					r.err = r.svcerr
+				}
+				// the running steps are killed rather than left
+				// running against the failed service.
+				if r.stage != nil {
+					r.stage()
+				}
+			}
+			r.mu.Unlock()
+		}
+	}()
+}
+
+// serviceErr returns the error of the first detached step that exited
+// unsuccessfully, if the pipeline should fail.
+func (r *Runtime) serviceErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.svcerr
+}
@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+// StepState defines the lifecycle state of a pipeline step.
+type StepState string
+
+// Step lifecycle states. A step starts in the pending state and ends
+// in exactly one of the terminal states.
+const (
+	StatePending StepState = "pending"
+	StateRunning StepState = "running"
+	StateSkipped StepState = "skipped"
+	StateSuccess StepState = "success"
+	StateFailure StepState = "failure"
+	StateKilled  StepState = "killed"
+	StateOOM     StepState = "oom_killed"
+	StateTimeout StepState = "timeout"
+)
+
+// Reasons a step is skipped, killed or allowed to fail.
+const (
+	ReasonFailure   = "pipeline failed"
+	ReasonSuccess   = "pipeline succeeded"
+	ReasonCancelled = "pipeline cancelled"
+	ReasonTimeout   = "pipeline timed out"
+	ReasonTracer    = "skipped by tracer"
+	ReasonFailFast  = "sibling step failed"
+	ReasonService   = "service failed"
+	ReasonAllowed   = "failure allowed"
+)
+
+// StepStatus defines the status of a pipeline step.
+type StepStatus struct {
+	Name     string    `json:"name"`
+	Alias    string    `json:"alias,omitempty"`
+	State    StepState `json:"state"`
+	Reason   string    `json:"reason,omitempty"`
+	ExitCode int       `json:"exit_code"`
+	Started  int64     `json:"started,omitempty"`
+	Finished int64     `json:"finished,omitempty"`
+}
+
+// Done returns true if the step is in a terminal state.
+func (s *StepStatus) Done() bool {
+	return s.State != StatePending && s.State != StateRunning
+}
+
+// Status returns a snapshot of the status of every step in the
+// pipeline, in execution order.
+func (r *Runtime) Status() []StepStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var list []StepStatus
+	for _, stage := range r.spec.Stages {
+		for _, proc := range stage.Steps {
+			list = append(list, *r.statusLocked(proc))
+		}
+	}
+	return list
+}
+
+// status returns a snapshot of the step status.
+func (r *Runtime) status(proc *backend.Step) StepStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.statusLocked(proc)
+}
+
+func (r *Runtime) statusLocked(proc *backend.Step) *StepStatus {
+	status, ok := r.steps[proc]
+	if !ok {
+		status = &StepStatus{
+			Name:  proc.Name,
+			Alias: proc.Alias,
+			State: StatePending,
+		}
+		r.steps[proc] = status
+	}
+	return status
+}
+
+// transition moves the step to the given state and returns true if
+// the transition is valid. A pending step may be started or skipped,
+// and a running step may be skipped, before the engine starts it, or
+// moved to any other terminal state. Steps cannot be started once the
+// pipeline is ending, and terminal states are final.
+func (r *Runtime) transition(proc *backend.Step, to StepState, code int, reason string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.statusLocked(proc)
+	switch {
+	case status.Done():
+		return false
+	case to == StateRunning && (status.State != StatePending || r.ending):
+		return false
+	case to != StateRunning && to != StateSkipped && status.State != StateRunning:
+		return false
+	}
+
+	now := time.Now().Unix()
+	switch to {
+	case StateRunning:
+		status.Started = now
+	case StateSkipped:
+		status.Started = 0
+	default:
+		status.Finished = now
+	}
+	status.State = to
+	status.ExitCode = code
+	status.Reason = reason
+	return true
+}
+
+// exitState returns the terminal state of a step that exited.
+func exitState(state *backend.State) StepState {
+	switch {
+	case state.OOMKilled:
+		return StateOOM
+	case state.ExitCode != 0:
+		return StateFailure
+	default:
+		return StateSuccess
+	}
+}
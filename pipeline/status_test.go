@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+func TestTransition(t *testing.T) {
+	step := &backend.Step{Name: "build"}
+	r := New(&backend.Config{})
+
+	if r.transition(step, StateSuccess, 0, "") {
+		t.Errorf("Want pending step cannot succeed before it is started")
+	}
+	if !r.transition(step, StateRunning, 0, "") {
+		t.Errorf("Want pending step can be started")
+	}
+	if r.transition(step, StateRunning, 0, "") {
+		t.Errorf("Want running step cannot be started twice")
+	}
+	if !r.transition(step, StateFailure, 1, "") {
+		t.Errorf("Want running step can fail")
+	}
+	if r.transition(step, StateSuccess, 0, "") {
+		t.Errorf("Want terminal state is final")
+	}
+
+	status := r.status(step)
+	if status.State != StateFailure || status.ExitCode != 1 {
+		t.Errorf("Want failure with exit code 1, got %s with exit code %d", status.State, status.ExitCode)
+	}
+	if status.Started == 0 || status.Finished == 0 {
+		t.Errorf("Want start and finish timestamps")
+	}
+}
+
+func TestTransitionEnding(t *testing.T) {
+	step := &backend.Step{Name: "build"}
+	r := New(&backend.Config{})
+	r.ending = true
+
+	if r.transition(step, StateRunning, 0, "") {
+		t.Errorf("Want steps cannot be started when the pipeline is ending")
+	}
+}
+
+func TestRuntimeStatus(t *testing.T) {
+	engine := newMockEngine()
+	engine.procs["test"] = &mockProc{code: 1}
+	engine.procs["oom"] = &mockProc{code: 137, oom: true}
+
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{Steps: []*backend.Step{{Name: "build", OnSuccess: true}}},
+			{Steps: []*backend.Step{{Name: "test", OnSuccess: true}, {Name: "oom", OnSuccess: true}}},
+			{Steps: []*backend.Step{{Name: "deploy", OnSuccess: true}}},
+			{Steps: []*backend.Step{{Name: "notify", OnFailure: true}}},
+		},
+	}
+
+	r := New(spec, WithEngine(engine))
+	r.Run()
+
+	want := map[string]StepState{
+		"build":  StateSuccess,
+		"test":   StateFailure,
+		"oom":    StateOOM,
+		"deploy": StateSkipped,
+		"notify": StateSuccess,
+	}
+	for _, status := range r.Status() {
+		if got := status.State; got != want[status.Name] {
+			t.Errorf("Want step %s state %s, got %s", status.Name, want[status.Name], got)
+		}
+	}
+}
+
+func TestRuntimeStatusTimeout(t *testing.T) {
+	engine := newMockEngine()
+	engine.procs["build"] = &mockProc{duration: time.Hour}
+
+	spec := &backend.Config{
+		Stages: []*backend.Stage{
+			{Steps: []*backend.Step{{Name: "build", OnSuccess: true}}},
+			{Steps: []*backend.Step{{Name: "deploy", OnSuccess: true}}},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	r := New(spec, WithEngine(engine), WithContext(ctx))
+	r.Run()
+
+	status := r.Status()
+	if got := status[0].State; got != StateTimeout {
+		t.Errorf("Want running step timed out, got %s", got)
+	}
+	if got := status[1]; got.State != StateSkipped || got.Reason != ReasonTimeout {
+		t.Errorf("Want pending step skipped on timeout, got %s: %s", got.State, got.Reason)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli"
+
+	_ "github.com/joho/godotenv/autoload"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "pipes"
+	app.Usage = "pipes provides a pipeline queue server for the cncd runtime"
+	app.Commands = []cli.Command{
+		serveCommand,
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
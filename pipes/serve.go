@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/rpc"
+	"github.com/cncd/pipeline/pipeline/rpc/queue"
+
+	"github.com/urfave/cli"
+)
+
+var serveCommand = cli.Command{
+	Name:   "serve",
+	Usage:  "serve an in-memory pipeline queue",
+	Action: serve,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:   "addr",
+			EnvVar: "PIPES_ADDR",
+			Value:  "127.0.0.1:9999",
+		},
+		cli.StringFlag{
+			Name:   "data",
+			EnvVar: "PIPES_DATA",
+			Usage:  "directory where logs and artifacts are written",
+			Value:  "data",
+		},
+		cli.DurationFlag{
+			Name:   "lease",
+			EnvVar: "PIPES_LEASE",
+			Usage:  "time an agent holds a pipeline without extending the lease",
+			Value:  time.Minute * 5,
+		},
+		cli.DurationFlag{
+			Name:   "retention",
+			EnvVar: "PIPES_RETENTION",
+			Usage:  "time the status of a completed pipeline is kept",
+			Value:  time.Hour * 24,
+		},
+		cli.StringFlag{
+			Name:   "secret",
+			EnvVar: "PIPES_SECRET",
+			Usage:  "shared secret used by all agents",
+		},
+		cli.StringSliceFlag{
+			Name:   "agent-token",
+			EnvVar: "PIPES_AGENT_TOKENS",
+			Usage:  "agent token in id=token format, may be repeated",
+		},
+		cli.StringFlag{
+			Name:   "tls-ca",
+			EnvVar: "PIPES_TLS_CA",
+			Usage:  "certificate authority bundle used to verify agent certificates",
+		},
+		cli.StringFlag{
+			Name:   "tls-cert",
+			EnvVar: "PIPES_TLS_CERT",
+			Usage:  "server certificate",
+		},
+		cli.StringFlag{
+			Name:   "tls-key",
+			EnvVar: "PIPES_TLS_KEY",
+			Usage:  "server certificate key",
+		},
+		cli.StringFlag{
+			Name:   "jwt-secret",
+			EnvVar: "PIPES_JWT_SECRET",
+			Usage:  "secret used to verify agent json web tokens",
+		},
+		cli.StringFlag{
+			Name:   "submit-token",
+			EnvVar: "PIPES_SUBMIT_TOKEN",
+			Usage:  "token required to submit, inspect and cancel pipelines",
+		},
+	},
+}
+
+func serve(c *cli.Context) error {
+	q := queue.New(
+		queue.WithLease(c.Duration("lease")),
+		queue.WithRetention(c.Duration("retention")),
+		queue.WithSink(queue.NewDiskSink(c.String("data"))),
+	)
+
+	auth, err := authenticator(c)
+	if err != nil {
+		return err
+	}
+	var opts []rpc.ServerOption
+	if auth != nil {
+		opts = append(opts, rpc.WithAuthenticator(auth))
+	}
+	if c.String("tls-ca") != "" {
+		if c.String("tls-cert") == "" {
+			return fmt.Errorf("The tls-ca flag requires a server certificate")
+		}
+		opts = append(opts, rpc.WithClientCertificates())
+	}
+
+	// pipelines are submitted with a separate token, since agents
+	// should not be able to schedule arbitrary containers.
+	h := &handler{queue: q}
+	if token := c.String("submit-token"); token != "" {
+		h.auth = rpc.NewSecretAuthenticator("", token)
+	} else {
+		log.Printf("pipes: pipeline submissions are not authenticated, no submit token")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", rpc.NewServer(q, opts...))
+	mux.Handle("/pipelines", h)
+	mux.Handle("/pipelines/", h)
+
+	log.Printf("pipes: serving on %s", c.String("addr"))
+	if c.String("tls-cert") == "" {
+		return http.ListenAndServe(c.String("addr"), mux)
+	}
+	certs, err := rpc.NewCertificates(
+		c.String("tls-ca"),
+		c.String("tls-cert"),
+		c.String("tls-key"),
+	)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:      c.String("addr"),
+		Handler:   mux,
+		TLSConfig: certs.ServerConfig(),
+	}
+	return server.ListenAndServeTLS("", "")
+}
+
+// handler accepts compiled pipeline submissions and reports their
+// status:
+//
+//	POST   /pipelines        submit a compiled pipeline.json
+//	GET    /pipelines/{id}   get the pipeline status
+//	DELETE /pipelines/{id}   cancel the pipeline
+//
+// Submissions accept a timeout, in minutes, and repeated label=key=value
+// query parameters restricting the agents that execute the pipeline.
+// If an authenticator is configured, requests must send its bearer
+// token.
+type handler struct {
+	queue *queue.Queue
+	auth  rpc.Authenticator
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		token := rpc.Bearer(r.Header.Get("Authorization"))
+		if _, err := h.auth.Authenticate(token); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/pipelines"), "/")
+	switch {
+	case id == "" && r.Method == "POST":
+		h.submit(w, r)
+	case id != "" && r.Method == "GET":
+		h.info(w, id)
+	case id != "" && r.Method == "DELETE":
+		h.cancel(w, id)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handler) submit(w http.ResponseWriter, r *http.Request) {
+	config := new(backend.Config)
+	if err := json.NewDecoder(r.Body).Decode(config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	labels := map[string]string{}
+	for _, label := range r.URL.Query()["label"] {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 {
+			http.Error(w, "invalid label "+label, http.StatusBadRequest)
+			return
+		}
+		labels[parts[0]] = parts[1]
+	}
+
+	var timeout int64
+	if s := r.URL.Query().Get("timeout"); s != "" {
+		var err error
+		if timeout, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "invalid timeout "+s, http.StatusBadRequest)
+			return
+		}
+	}
+
+	id := h.queue.Push(&rpc.Pipeline{Config: config, Timeout: timeout}, labels)
+	log.Printf("pipes: pipeline submitted: %s", id)
+
+	info, _ := h.queue.Info(id)
+	writeJSON(w, http.StatusCreated, info)
+}
+
+func (h *handler) info(w http.ResponseWriter, id string) {
+	info, err := h.queue.Info(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (h *handler) cancel(w http.ResponseWriter, id string) {
+	if err := h.queue.Cancel(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Printf("pipes: pipeline cancelled: %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticator returns an authenticator that accepts agents using any
+// of the configured credentials, or nil if agents are not authenticated.
+func authenticator(c *cli.Context) (rpc.Authenticator, error) {
+	var auths []rpc.Authenticator
+	if secret := c.String("secret"); secret != "" {
+		auths = append(auths, rpc.NewSecretAuthenticator("", secret))
+	}
+	if list := c.StringSlice("agent-token"); len(list) != 0 {
+		tokens := map[string]string{}
+		for _, item := range list {
+			parts := strings.SplitN(item, "=", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return nil, fmt.Errorf("Invalid agent token %q, expected id=token", item)
+			}
+			tokens[parts[0]] = parts[1]
+		}
+		auths = append(auths, rpc.NewTokenAuthenticator(tokens))
+	}
+	if secret := c.String("jwt-secret"); secret != "" {
+		auths = append(auths, rpc.NewJWTAuthenticator([]byte(secret)))
+	}
+	if len(auths) == 0 {
+		return nil, nil
+	}
+	return rpc.AuthenticatorFunc(func(token string) (*rpc.Agent, error) {
+		for _, auth := range auths {
+			if agent, err := auth.Authenticate(token); err == nil {
+				return agent, nil
+			}
+		}
+		return nil, rpc.ErrUnauthorized
+	}), nil
+}
+
+// helper function writes the value as json.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}